@@ -13,24 +13,42 @@ import (
 	"strings"
 )
 
+// DefaultRule - rule keyword that leaves column out of INSERT, so the database fills it with its default
+const DefaultRule = "default"
+
 type DataGenerator[T any] struct {
 	gen func() T
 }
 
+// patterns of rules, compiled once as generators are created for every column
+var (
+	rulePattern       = regexp.MustCompile(`([a-zA-Z]+)\s*(\((\d+)(,\s*(\d+))?\))?\s*`)
+	customRulePattern = regexp.MustCompile(`([a-zA-Z]+)\s*\[(.+)\]`)
+	arrayRulePattern  = regexp.MustCompile(`(?i)^\s*array\s*\((.+?)(,\s*(\d+)\s*,\s*(\d+))?\s*\)\s*$`)
+	ruleValuesPattern = regexp.MustCompile(`^\s*([a-zA-Z]+)\s*\[(.+)\]\s*$`)
+)
+
+// default number of elements of generated arrays
+const (
+	DefaultArrayMinLen = 0
+	DefaultArrayMaxLen = 5
+)
+
 func RuleToGeneratorFunc(rule string) (func() string, error) {
+	if arrayGen, ok, err := arrayRuleGenerator(rule); ok {
+		return arrayGen, err
+	}
 	var customRuleFunc, err = customGenerator(rule)
 	if err == nil {
 		return customRuleFunc, nil
 	}
-	var regex = `([a-zA-Z]+)\s*(\((\d+)(,\s*(\d+))?\))?\s*`
-	var pattern = regexp.MustCompile(regex)
 	if rule == "" {
 		return nil, fmt.Errorf("empty rule")
 	}
 	rule = strings.ToLower(rule)
-	var matches = pattern.FindStringSubmatch(rule)
+	var matches = rulePattern.FindStringSubmatch(rule)
 	if len(matches) < 5 {
-		return nil, fmt.Errorf("invalid rule, rule must match pattern: %s", regex)
+		return nil, fmt.Errorf("invalid rule, rule must match pattern: %s", rulePattern)
 	}
 	_ = matches[5]
 	var typeName = matches[1]
@@ -140,14 +158,12 @@ func RuleToGeneratorFunc(rule string) (func() string, error) {
 // one%40 means 'one' generates with 40% probability
 // constant[one] means 'one' generates always
 func customGenerator(rule string) (func() string, error) {
-	var regex = `([a-zA-Z]+)\s*\[(.+)\]`
-	var pattern = regexp.MustCompile(regex)
 	if rule == "" {
 		return nil, fmt.Errorf("empty rule: %s", rule)
 	}
-	var matches = pattern.FindStringSubmatch(rule)
+	var matches = customRulePattern.FindStringSubmatch(rule)
 	if len(matches) < 3 {
-		return nil, fmt.Errorf("invalid rule, rule must match pattern: %s", regex)
+		return nil, fmt.Errorf("invalid rule, rule must match pattern: %s", customRulePattern)
 	}
 	var typeName = matches[1]
 	var values = strings.Split(matches[2], ",")
@@ -155,20 +171,9 @@ func customGenerator(rule string) (func() string, error) {
 	typeName = strings.ToLower(typeName)
 	switch typeName {
 	case "oneof":
-		var probs = make([]int, 0)
-		var names = make([]string, 0)
-		for _, v := range values {
-			var parts = strings.Split(v, "%")
-			if len(parts) != 2 {
-				return nil, fmt.Errorf("invalid rule, oneof rule must contain name and probability")
-			}
-			name := parts[0]
-			prob, err := strconv.Atoi(parts[1])
-			if err != nil {
-				return nil, fmt.Errorf("invalid rule, probability must be an integer")
-			}
-			names = append(names, name)
-			probs = append(probs, prob)
+		names, probs, err := parseOneOf(values)
+		if err != nil {
+			return nil, err
 		}
 		generator = func() string {
 			// generate names[i] with probability prob[i]
@@ -194,6 +199,115 @@ func customGenerator(rule string) (func() string, error) {
 
 }
 
+// for rule looks like: "array(email, 0, 5)" or "array(int(1, 10))"
+// array(rule, min, max) generates arrays of min to max elements, each element is generated by rule
+func arrayRuleGenerator(rule string) (func() string, bool, error) {
+	var matches = arrayRulePattern.FindStringSubmatch(rule)
+	if matches == nil {
+		return nil, false, nil
+	}
+	var minLen, maxLen = DefaultArrayMinLen, DefaultArrayMaxLen
+	if matches[2] != "" {
+		minLen, _ = funcutil.ParseInt(matches[3])
+		maxLen, _ = funcutil.ParseInt(matches[4])
+		if minLen > maxLen {
+			return nil, true, fmt.Errorf("invalid rule, array min length %d is greater than max length %d", minLen, maxLen)
+		}
+	}
+	elemGen, err := RuleToGeneratorFunc(matches[1])
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid array element rule: %v", err)
+	}
+	return ArrayGenerator(elemGen, minLen, maxLen), true, nil
+}
+
+// ArrayGenerator returns generator of PostgreSQL array literals with minLen to maxLen elements
+func ArrayGenerator(elemGen func() string, minLen, maxLen int) func() string {
+	return func() string {
+		n := minLen
+		if maxLen > minLen {
+			n += rand.Intn(maxLen - minLen + 1)
+		}
+		elems := make([]string, n)
+		for i := range elems {
+			elems[i] = elemGen()
+		}
+		return ArrayLiteral(elems)
+	}
+}
+
+// ArrayLiteral builds PostgreSQL array literal, e.g. {"a","b"}
+func ArrayLiteral(elems []string) string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, e := range elems {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('"')
+		sb.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(e))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// RowLiteral builds PostgreSQL composite type literal, e.g. ("a","b")
+func RowLiteral(values []string) string {
+	var sb strings.Builder
+	sb.WriteByte('(')
+	for i, v := range values {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('"')
+		sb.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+func parseOneOf(values []string) ([]string, []int, error) {
+	var probs = make([]int, 0)
+	var names = make([]string, 0)
+	for _, v := range values {
+		var parts = strings.Split(v, "%")
+		if len(parts) != 2 {
+			return nil, nil, fmt.Errorf("invalid rule, oneof rule must contain name and probability")
+		}
+		name := strings.TrimSpace(parts[0])
+		prob, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid rule, probability must be an integer")
+		}
+		names = append(names, name)
+		probs = append(probs, prob)
+	}
+	return names, probs, nil
+}
+
+// RuleValues returns all values which oneof and constant rules can generate.
+// For other rules false is returned.
+func RuleValues(rule string) ([]string, bool) {
+	var matches = ruleValuesPattern.FindStringSubmatch(rule)
+	if matches == nil {
+		return nil, false
+	}
+	var values = strings.Split(matches[2], ",")
+	switch strings.ToLower(matches[1]) {
+	case "oneof":
+		names, _, err := parseOneOf(values)
+		if err != nil {
+			return nil, false
+		}
+		return names, true
+	case "constant":
+		return []string{matches[2]}, true
+	}
+	return nil, false
+}
+
 func intGenerator(nStr, mStr string) (func() int, error) {
 	var generator func() int
 	if nStr != "" && mStr != "" {
@@ -2,6 +2,7 @@ package datagen
 
 import (
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -48,3 +49,35 @@ func Test_ruleToGenerator_timestamp(t *testing.T) {
 	t.Logf("Value: %v", ts)
 
 }
+
+func Test_ruleToGenerator_array(t *testing.T) {
+	r, err := RuleToGeneratorFunc("array(int(1, 10), 2, 4)")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	val := r()
+	if !strings.HasPrefix(val, "{") || !strings.HasSuffix(val, "}") {
+		t.Fatalf("Error: %v is not an array literal", val)
+	}
+	elems := strings.Split(strings.Trim(val, "{}"), ",")
+	if len(elems) < 2 || len(elems) > 4 {
+		t.Errorf("Error: %v has %d elements", val, len(elems))
+	}
+	for _, e := range elems {
+		n, err := strconv.Atoi(strings.Trim(e, `"`))
+		if err != nil || n < 1 || n > 10 {
+			t.Errorf("Error: invalid element %v", e)
+		}
+	}
+
+	t.Logf("Value: %v", val)
+}
+
+func Test_arrayLiteral(t *testing.T) {
+	val := ArrayLiteral([]string{`a "b"`, `c\d`, "e,f"})
+	expected := `{"a \"b\"","c\\d","e,f"}`
+	if val != expected {
+		t.Errorf("Error: %v, expected %v", val, expected)
+	}
+}
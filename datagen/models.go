@@ -4,8 +4,25 @@ type TableRule struct {
 	TableName string
 	RowNum    int               `yaml:"num"`
 	Rules     map[string]string `yaml:"columns"`
+	Nulls     map[string]int    `yaml:"nulls"` // key contains column name and value contains NULL probability in percents
+	Tree      *TreeRule         `yaml:"tree"`  // generate rows as a hierarchy of a self-referencing foreign key
+	// Partitions - names of partitions of a partitioned table, only values of the partition key routed to them are generated
+	Partitions []string `yaml:"partitions"`
+}
+
+// TreeRule - settings of hierarchy generation for a self-referencing foreign key
+type TreeRule struct {
+	ForeignKey    string `yaml:"foreign_key"`    // constraint or column name, may be omitted if the table has one self-reference
+	Roots         int    `yaml:"roots"`          // number of rows without parent, 1 by default
+	MaxDepth      int    `yaml:"max_depth"`      // maximum depth of a row (roots have depth 0), 0 means unlimited
+	Fanout        string `yaml:"fanout"`         // distribution of children: uniform (default), preferential or fixed(n)
+	PathColumn    string `yaml:"path_column"`    // column filled with the materialized path of the row
+	PathSeparator string `yaml:"path_separator"` // separator of path elements, "/" by default
+	DepthColumn   string `yaml:"depth_column"`   // column filled with the depth of the row
 }
 
 type TablesRules struct {
-	Rules map[string]TableRule // key contains table name and value contains rules for that table
+	NullPercent int                  `yaml:"null_percent"` // default NULL probability in percents for nullable columns
+	Exclude     []string             `yaml:"exclude"`      // tables (names or globs) not to generate data for
+	Rules       map[string]TableRule // key contains table name and value contains rules for that table
 }
@@ -0,0 +1,449 @@
+package dbutils
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Queries of the catalog snapshot, each reads one kind of objects of the whole database
+const (
+	systemSchemasCondition = `
+			n.nspname NOT IN ('pg_catalog', 'information_schema')
+			AND n.nspname NOT LIKE 'pg\_toast%'
+			AND n.nspname NOT LIKE 'pg\_temp\_%'`
+
+	getRelationsQuery = `
+		SELECT
+			c.oid,
+			n.nspname,
+			c.relname,
+			c.relkind::text,
+			c.relispartition,
+			COALESCE(pn.nspname, '') AS parent_schema,
+			COALESCE(p.relname, '') AS parent_name,
+			COALESCE(pg_get_expr(c.relpartbound, c.oid), '') AS partition_bound,
+			CASE WHEN c.relkind = 'p' THEN pg_get_partkeydef(c.oid) ELSE '' END AS partition_key
+		FROM pg_class c
+		JOIN pg_namespace n
+			ON n.oid = c.relnamespace
+		LEFT JOIN pg_inherits i
+			ON c.relispartition
+			AND i.inhrelid = c.oid
+		LEFT JOIN pg_class p
+			ON p.oid = i.inhparent
+		LEFT JOIN pg_namespace pn
+			ON pn.oid = p.relnamespace
+		WHERE c.relkind IN ('r', 'v', 'm', 'f', 'p')
+			AND` + systemSchemasCondition + `
+		ORDER BY n.nspname, c.relname
+	`
+
+	// columns of relations and attributes of composite types
+	getColumnsQuery = `
+		SELECT
+			a.attrelid,
+			a.attname,
+			a.atttypid,
+			format_type(a.atttypid, a.atttypmod),
+			a.attnotnull,
+			CASE WHEN a.attgenerated = '' THEN COALESCE(pg_get_expr(d.adbin, d.adrelid), '') ELSE '' END,
+			a.attidentity::text,
+			a.attgenerated <> ''
+		FROM pg_attribute a
+		JOIN pg_class c
+			ON c.oid = a.attrelid
+		JOIN pg_namespace n
+			ON n.oid = c.relnamespace
+		LEFT JOIN pg_attrdef d
+			ON d.adrelid = a.attrelid
+			AND d.adnum = a.attnum
+		WHERE a.attnum > 0
+			AND NOT a.attisdropped
+			AND c.relkind IN ('r', 'v', 'm', 'f', 'p', 'c')
+			AND` + systemSchemasCondition + `
+		ORDER BY a.attrelid, a.attnum
+	`
+
+	// all types except row types of tables
+	getTypesQuery = `
+		SELECT
+			t.oid,
+			n.nspname,
+			t.typname,
+			t.typtype::text,
+			t.typcategory::text,
+			t.typelem,
+			t.typbasetype,
+			CASE WHEN t.typtype = 'd' THEN format_type(t.typbasetype, t.typtypmod) ELSE '' END,
+			t.typrelid,
+			format_type(t.oid, NULL)
+		FROM pg_type t
+		JOIN pg_namespace n
+			ON n.oid = t.typnamespace
+		LEFT JOIN pg_class c
+			ON c.oid = t.typrelid
+		WHERE t.typtype <> 'c' OR c.relkind = 'c'
+	`
+
+	getEnumLabelsQuery = `
+		SELECT enumtypid, enumlabel
+		FROM pg_enum
+		ORDER BY enumtypid, enumsortorder
+	`
+
+	// primary and foreign keys, one row per column
+	getKeysQuery = `
+		SELECT
+			con.conrelid,
+			con.conname,
+			con.contype::text,
+			con.condeferrable,
+			a.attname,
+			COALESCE(fn.nspname, ''),
+			COALESCE(fc.relname, ''),
+			COALESCE(fa.attname, '')
+		FROM pg_constraint con
+		CROSS JOIN LATERAL unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(attnum, fattnum, ord)
+		JOIN pg_attribute a
+			ON a.attrelid = con.conrelid
+			AND a.attnum = k.attnum
+		LEFT JOIN pg_class fc
+			ON fc.oid = con.confrelid
+		LEFT JOIN pg_namespace fn
+			ON fn.oid = fc.relnamespace
+		LEFT JOIN pg_attribute fa
+			ON fa.attrelid = con.confrelid
+			AND fa.attnum = k.fattnum
+		WHERE con.contype IN ('p', 'f')
+			AND con.conparentid = 0
+		ORDER BY con.conrelid, con.conname, k.ord
+	`
+
+	// CHECK constraints of tables and domains
+	getCheckConstraintsQuery = `
+		SELECT con.conrelid, con.contypid, con.conname, pg_get_constraintdef(con.oid)
+		FROM pg_constraint con
+		WHERE con.contype = 'c'
+		ORDER BY con.conname
+	`
+
+	// unique indexes (including primary keys and UNIQUE constraints), one row per column
+	getUniqueIndexesQuery = `
+		SELECT
+			i.indrelid,
+			ic.relname,
+			a.attname,
+			COALESCE(pg_get_expr(i.indpred, i.indrelid), '')
+		FROM pg_index i
+		JOIN pg_class ic
+			ON ic.oid = i.indexrelid
+		CROSS JOIN LATERAL unnest(i.indkey) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute a
+			ON a.attrelid = i.indrelid
+			AND a.attnum = k.attnum
+		WHERE i.indisunique
+			AND i.indexprs IS NULL
+		ORDER BY i.indrelid, ic.relname, k.ord
+	`
+)
+
+// catalogRelation - table, view or another relation from pg_class
+type catalogRelation struct {
+	oid          uint32
+	schema       string
+	name         string
+	relkind      string
+	isPartition  bool
+	parentSchema string
+	parentName   string
+	bound        string
+	partitionKey string
+}
+
+// catalogColumn - column of a relation or attribute of a composite type from pg_attribute
+type catalogColumn struct {
+	relid     uint32
+	name      string
+	typeOid   uint32
+	formatted string // type name with modifiers, e.g. character varying(50)
+	notNull   bool
+	dflt      string
+	identity  string // pg_attribute.attidentity: a - ALWAYS, d - BY DEFAULT, empty if not identity
+	generated bool
+}
+
+// catalogType - type from pg_type
+type catalogType struct {
+	oid           uint32
+	schema        string
+	name          string
+	kind          string // pg_type.typtype: b - base, c - composite, d - domain, e - enum
+	category      string // pg_type.typcategory: A - array
+	elem          uint32 // element type of array
+	base          uint32 // base type of domain
+	baseFormatted string // base type of domain with modifiers
+	relid         uint32 // pg_class entry of composite type
+	formatted     string
+}
+
+// catalogKey - primary or foreign key from pg_constraint
+type catalogKey struct {
+	relid      uint32
+	name       string
+	kind       string // p - primary key, f - foreign key
+	deferrable bool
+	columns    []string
+	refSchema  string
+	refTable   string
+	refColumns []string
+}
+
+// catalogCheck - CHECK constraint of a table (relid) or a domain (typid)
+type catalogCheck struct {
+	relid uint32
+	typid uint32
+	CheckConstraint
+}
+
+// catalogIndex - unique index from pg_index
+type catalogIndex struct {
+	relid uint32
+	UniqueConstraint
+}
+
+// catalogSnapshot - objects of the whole database read by a fixed number of queries
+type catalogSnapshot struct {
+	relations  []catalogRelation
+	columns    map[uint32][]catalogColumn // by relation oid, in attribute order
+	types      map[uint32]catalogType
+	enumLabels map[uint32][]string
+	keys       map[uint32][]catalogKey
+	checks     map[uint32][]CheckConstraint // CHECK constraints of tables by relation oid
+	domains    map[uint32][]CheckConstraint // CHECK constraints of domains by type oid
+	indexes    map[uint32][]UniqueConstraint
+}
+
+// loadCatalog reads the catalog snapshot
+func loadCatalog(db *sql.DB) (*catalogSnapshot, error) {
+	s := &catalogSnapshot{
+		columns:    make(map[uint32][]catalogColumn),
+		types:      make(map[uint32]catalogType),
+		enumLabels: make(map[uint32][]string),
+		keys:       make(map[uint32][]catalogKey),
+		checks:     make(map[uint32][]CheckConstraint),
+		domains:    make(map[uint32][]CheckConstraint),
+		indexes:    make(map[uint32][]UniqueConstraint),
+	}
+	loaders := []struct {
+		name  string
+		query string
+		scan  func(rows *sql.Rows) error
+	}{
+		{"relations", getRelationsQuery, s.scanRelation},
+		{"columns", getColumnsQuery, s.scanColumn},
+		{"types", getTypesQuery, s.scanType},
+		{"enum labels", getEnumLabelsQuery, s.scanEnumLabel},
+		{"keys", getKeysQuery, s.scanKey},
+		{"CHECK constraints", getCheckConstraintsQuery, s.scanCheck},
+		{"unique indexes", getUniqueIndexesQuery, s.scanIndex},
+	}
+	for _, loader := range loaders {
+		if err := queryRows(db, loader.query, loader.scan); err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", loader.name, err)
+		}
+	}
+	return s, nil
+}
+
+// queryRows runs query and calls scan for every row
+func queryRows(db *sql.DB, query string, scan func(rows *sql.Rows) error) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *catalogSnapshot) scanRelation(rows *sql.Rows) error {
+	var r catalogRelation
+	err := rows.Scan(&r.oid, &r.schema, &r.name, &r.relkind, &r.isPartition,
+		&r.parentSchema, &r.parentName, &r.bound, &r.partitionKey)
+	if err != nil {
+		return err
+	}
+	s.relations = append(s.relations, r)
+	return nil
+}
+
+func (s *catalogSnapshot) scanColumn(rows *sql.Rows) error {
+	var c catalogColumn
+	err := rows.Scan(&c.relid, &c.name, &c.typeOid, &c.formatted, &c.notNull, &c.dflt, &c.identity, &c.generated)
+	if err != nil {
+		return err
+	}
+	s.columns[c.relid] = append(s.columns[c.relid], c)
+	return nil
+}
+
+func (s *catalogSnapshot) scanType(rows *sql.Rows) error {
+	var t catalogType
+	err := rows.Scan(&t.oid, &t.schema, &t.name, &t.kind, &t.category, &t.elem, &t.base, &t.baseFormatted,
+		&t.relid, &t.formatted)
+	if err != nil {
+		return err
+	}
+	s.types[t.oid] = t
+	return nil
+}
+
+func (s *catalogSnapshot) scanEnumLabel(rows *sql.Rows) error {
+	var typid uint32
+	var label string
+	if err := rows.Scan(&typid, &label); err != nil {
+		return err
+	}
+	s.enumLabels[typid] = append(s.enumLabels[typid], label)
+	return nil
+}
+
+func (s *catalogSnapshot) scanKey(rows *sql.Rows) error {
+	var k catalogKey
+	var col, refCol string
+	if err := rows.Scan(&k.relid, &k.name, &k.kind, &k.deferrable, &col, &k.refSchema, &k.refTable, &refCol); err != nil {
+		return err
+	}
+	// rows are ordered by constraint, so columns of one constraint are adjacent
+	keys := s.keys[k.relid]
+	if len(keys) == 0 || keys[len(keys)-1].name != k.name {
+		keys = append(keys, k)
+	}
+	last := &keys[len(keys)-1]
+	last.columns = append(last.columns, col)
+	if last.kind == "f" {
+		last.refColumns = append(last.refColumns, refCol)
+	}
+	s.keys[k.relid] = keys
+	return nil
+}
+
+func (s *catalogSnapshot) scanCheck(rows *sql.Rows) error {
+	var c catalogCheck
+	if err := rows.Scan(&c.relid, &c.typid, &c.Name, &c.Definition); err != nil {
+		return err
+	}
+	if c.relid != 0 {
+		s.checks[c.relid] = append(s.checks[c.relid], c.CheckConstraint)
+	} else {
+		s.domains[c.typid] = append(s.domains[c.typid], c.CheckConstraint)
+	}
+	return nil
+}
+
+func (s *catalogSnapshot) scanIndex(rows *sql.Rows) error {
+	var i catalogIndex
+	var col string
+	if err := rows.Scan(&i.relid, &i.Name, &col, &i.Predicate); err != nil {
+		return err
+	}
+	// rows are ordered by index, so columns of one index are adjacent
+	indexes := s.indexes[i.relid]
+	if len(indexes) == 0 || indexes[len(indexes)-1].Name != i.Name {
+		indexes = append(indexes, i.UniqueConstraint)
+	}
+	last := &indexes[len(indexes)-1]
+	last.Columns = append(last.Columns, col)
+	s.indexes[i.relid] = indexes
+	return nil
+}
+
+// tables builds tables of schemas passing the filter
+func (s *catalogSnapshot) tables(filter SchemaFilter) ([]Table, error) {
+	tables := make([]Table, 0)
+	for _, r := range s.relations {
+		if !filter.Match(r.schema) {
+			continue
+		}
+		table, err := s.table(r)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	linkPartitions(tables)
+	return tables, nil
+}
+
+func (s *catalogSnapshot) table(r catalogRelation) (Table, error) {
+	table := Table{
+		Schema:         r.schema,
+		Name:           r.name,
+		Kind:           relationKind(r.relkind, r.isPartition),
+		PartitionBound: r.bound,
+		PartitionKey:   r.partitionKey,
+		Columns:        make(map[string]Column),
+		PrimaryKeys:    make(map[string]bool),
+		ForeignKeys:    make([]ForeignKey, 0),
+		Uniques:        s.indexes[r.oid],
+		Checks:         s.checks[r.oid],
+		RowNum:         0,
+		Rules:          make(map[string]func() string),
+	}
+	if r.isPartition {
+		table.PartitionOf = QualifyName(r.parentSchema, r.parentName)
+	}
+	if table.Uniques == nil {
+		table.Uniques = make([]UniqueConstraint, 0)
+	}
+	if table.Checks == nil {
+		table.Checks = make([]CheckConstraint, 0)
+	}
+
+	for _, c := range s.columns[r.oid] {
+		dataType, err := s.resolveType(c.typeOid, c.formatted)
+		if err != nil {
+			// the relation may be a view or never generated, the column fails only a table generated with it
+			dataType = Unsupported{Name: c.formatted}
+		}
+		col := Column{
+			Name:        c.name,
+			DataType:    dataType,
+			IsNullable:  !c.notNull,
+			Default:     c.dflt,
+			IsIdentity:  c.identity != "",
+			IsGenerated: c.generated,
+		}
+		switch c.identity {
+		case "a":
+			col.IdentityGeneration = "ALWAYS"
+		case "d":
+			col.IdentityGeneration = "BY DEFAULT"
+		}
+		table.Columns[c.name] = col
+	}
+
+	for _, k := range s.keys[r.oid] {
+		switch k.kind {
+		case "p":
+			for _, colName := range k.columns {
+				table.PrimaryKeys[colName] = true
+			}
+		case "f":
+			table.ForeignKeys = append(table.ForeignKeys, ForeignKey{
+				Name:       k.name,
+				Columns:    k.columns,
+				RefSchema:  k.refSchema,
+				RefTable:   k.refTable,
+				RefColumns: k.refColumns,
+				Deferrable: k.deferrable,
+			})
+		}
+	}
+	completeTable(&table)
+	return table, nil
+}
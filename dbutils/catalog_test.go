@@ -0,0 +1,211 @@
+package dbutils
+
+import (
+	"database/sql"
+	"fmt"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/victornguen/db-faker/datagen"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestCatalog() *catalogSnapshot {
+	return &catalogSnapshot{
+		relations: []catalogRelation{
+			{oid: 100, schema: "shop", name: "customers", relkind: "r"},
+			{oid: 101, schema: "shop", name: "orders", relkind: "r"},
+			{oid: 102, schema: "audit", name: "log", relkind: "r"},
+		},
+		columns: map[uint32][]catalogColumn{
+			100: {{relid: 100, name: "id", typeOid: 23, formatted: "integer", notNull: true, identity: "a"}},
+			101: {
+				{relid: 101, name: "id", typeOid: 23, formatted: "integer", notNull: true,
+					dflt: "nextval('shop.orders_id_seq'::regclass)"},
+				{relid: 101, name: "customer_id", typeOid: 23, formatted: "integer"},
+				{relid: 101, name: "status", typeOid: 500, formatted: "shop.status"},
+				{relid: 101, name: "amount", typeOid: 501, formatted: "shop.amount"},
+				{relid: 101, name: "address", typeOid: 502, formatted: "shop.address"},
+				{relid: 101, name: "tags", typeOid: 1015, formatted: "character varying(20)[]"},
+				{relid: 101, name: "note", typeOid: 1043, formatted: "character varying(50)", generated: true},
+			},
+			600: {{relid: 600, name: "city", typeOid: 25, formatted: "text"}},
+		},
+		types: map[uint32]catalogType{
+			500:  {oid: 500, schema: "shop", name: "status", kind: "e", category: "E"},
+			501:  {oid: 501, schema: "shop", name: "amount", kind: "d", category: "N", base: 1700, baseFormatted: "numeric(10,2)"},
+			502:  {oid: 502, schema: "shop", name: "address", kind: "c", category: "C", relid: 600},
+			1015: {oid: 1015, schema: "pg_catalog", name: "_varchar", kind: "b", category: "A", elem: 1043},
+		},
+		enumLabels: map[uint32][]string{500: {"new", "paid"}},
+		keys: map[uint32][]catalogKey{
+			101: {
+				{relid: 101, name: "orders_customer_fk", kind: "f", deferrable: true, columns: []string{"customer_id"},
+					refSchema: "shop", refTable: "customers", refColumns: []string{"id"}},
+				{relid: 101, name: "orders_pkey", kind: "p", columns: []string{"id"}},
+			},
+		},
+		checks: map[uint32][]CheckConstraint{
+			101: {{Name: "orders_customer_check", Definition: "CHECK ((customer_id > 0))"}},
+		},
+		domains: map[uint32][]CheckConstraint{
+			501: {{Name: "amount_check", Definition: "CHECK ((VALUE > (0)::numeric))"}},
+		},
+		indexes: map[uint32][]UniqueConstraint{
+			101: {{Name: "orders_pkey", Columns: []string{"id"}}},
+		},
+	}
+}
+
+func TestCatalogSnapshot_Tables(t *testing.T) {
+	tables, err := newTestCatalog().tables(SchemaFilter{Include: []string{"shop"}})
+	assert.NoError(t, err)
+	assert.Len(t, tables, 2)
+
+	customers, orders := tables[0], tables[1]
+	assert.True(t, customers.Columns["id"].IsIdentity)
+	assert.Equal(t, "ALWAYS", customers.Columns["id"].IdentityGeneration)
+
+	assert.Equal(t, "shop.orders", orders.QualifiedName())
+	assert.Equal(t, KindTable, orders.Kind)
+	assert.Equal(t, map[string]bool{"id": true}, orders.PrimaryKeys)
+	assert.Equal(t, []string{"shop.customers"}, orders.DependsOn)
+	assert.Len(t, orders.ForeignKeys, 1)
+	assert.True(t, orders.ForeignKeys[0].Deferrable)
+	assert.True(t, orders.Columns["customer_id"].IsForeignKey)
+	assert.True(t, orders.Columns["customer_id"].IsNullable)
+	assert.False(t, orders.Columns["id"].IsNullable)
+	assert.Equal(t, 0.0, orders.Columns["customer_id"].Check.Min.OrElse(-1))
+	assert.True(t, orders.Columns["note"].IsGenerated)
+	assert.Len(t, orders.Uniques, 1)
+
+	assert.Equal(t, Enum{Name: "shop.status", Labels: []string{"new", "paid"}}, orders.Columns["status"].DataType)
+	domain := orders.Columns["amount"].DataType.(Domain)
+	assert.Equal(t, 2, domain.Base.(Numeric).Scale.OrElse(0))
+	assert.True(t, domain.Check.Min.IsPresent())
+	composite := orders.Columns["address"].DataType.(Composite)
+	assert.Equal(t, "city", composite.Attributes[0].Name)
+	tags := orders.Columns["tags"].DataType.(Array)
+	assert.Equal(t, 20, tags.Elem.(VarChar).MaxLen.OrElse(0))
+}
+
+func TestCatalogSnapshot_UnsupportedType(t *testing.T) {
+	catalog := newTestCatalog()
+	catalog.types[503] = catalogType{oid: 503, schema: "shop", name: "period", kind: "r"}
+	catalog.columns[102] = []catalogColumn{
+		{relid: 102, name: "during", typeOid: 503, formatted: "shop.period"},
+		{relid: 102, name: "email", typeOid: 16385, formatted: "citext", notNull: true},
+		{relid: 102, name: "note", typeOid: 25, formatted: "text"},
+	}
+	// one column of a type without a generator doesn't fail the other tables
+	tables, err := catalog.tables(SchemaFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, tables, 3)
+	log := tables[2]
+	assert.Equal(t, Unsupported{Name: "shop.period"}, log.Columns["during"].DataType)
+	assert.Equal(t, Unsupported{Name: "citext"}, log.Columns["email"].DataType)
+	assert.Len(t, log.Warnings, 2)
+	assert.Contains(t, log.Warnings[0], "audit.log.during")
+
+	// the NOT NULL column needs a rule, the nullable one is left NULL
+	rules := datagen.TablesRules{Rules: map[string]datagen.TableRule{"log": {RowNum: 10}}}
+	assert.ErrorContains(t, ApplyRulesToTables(&tables, rules), "audit.log.email")
+	rules.Rules["log"] = datagen.TableRule{RowNum: 10, Rules: map[string]string{"email": "email"}}
+	assert.NoError(t, ApplyRulesToTables(&tables, rules))
+	assert.Contains(t, tables[2].Columns["email"].DataGen(), "@")
+	assert.Equal(t, 100, tables[2].Columns["during"].NullPercent)
+
+	// the type is kept by schema files
+	path := filepath.Join(t.TempDir(), "schema.yaml")
+	assert.NoError(t, WriteSchemaFile(path, "", tables))
+	loaded, err := LoadSchemaFile(path, SchemaFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, Unsupported{Name: "citext"}, loaded[2].Columns["email"].DataType)
+}
+
+// generatedCatalog returns snapshot of n tables, each referencing the previous one
+func generatedCatalog(n int) *catalogSnapshot {
+	catalog := &catalogSnapshot{
+		columns: make(map[uint32][]catalogColumn),
+		types:   map[uint32]catalogType{},
+		keys:    make(map[uint32][]catalogKey),
+		checks:  make(map[uint32][]CheckConstraint),
+		indexes: make(map[uint32][]UniqueConstraint),
+	}
+	for i := 0; i < n; i++ {
+		oid := uint32(1000 + i)
+		name := fmt.Sprintf("table_%04d", i)
+		catalog.relations = append(catalog.relations, catalogRelation{oid: oid, schema: "public", name: name, relkind: "r"})
+		catalog.columns[oid] = []catalogColumn{
+			{relid: oid, name: "id", typeOid: 20, formatted: "bigint", notNull: true, identity: "d"},
+			{relid: oid, name: "parent_id", typeOid: 20, formatted: "bigint"},
+			{relid: oid, name: "code", typeOid: 1043, formatted: "character varying(32)", notNull: true},
+			{relid: oid, name: "amount", typeOid: 1700, formatted: "numeric(12,2)"},
+			{relid: oid, name: "created_at", typeOid: 1114, formatted: "timestamp without time zone", dflt: "now()"},
+		}
+		catalog.keys[oid] = []catalogKey{{relid: oid, name: name + "_pkey", kind: "p", columns: []string{"id"}}}
+		if i > 0 {
+			catalog.keys[oid] = append(catalog.keys[oid], catalogKey{relid: oid, name: name + "_parent_fk", kind: "f",
+				columns: []string{"parent_id"}, refSchema: "public", refTable: fmt.Sprintf("table_%04d", i-1),
+				refColumns: []string{"id"}})
+		}
+		catalog.checks[oid] = []CheckConstraint{{Name: name + "_amount_check", Definition: "CHECK ((amount >= (0)::numeric))"}}
+		catalog.indexes[oid] = []UniqueConstraint{{Name: name + "_code_key", Columns: []string{"code"}}}
+	}
+	return catalog
+}
+
+func BenchmarkCatalogSnapshot_Tables(b *testing.B) {
+	catalog := generatedCatalog(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tables, err := catalog.tables(SchemaFilter{})
+		if err != nil || len(tables) != 1000 {
+			b.Fatal(tables, err)
+		}
+	}
+}
+
+// BenchmarkGetTablesWithDependencies introspects a live database with 1000 generated tables.
+// It runs only if DB_FAKER_BENCH_DSN is set, e.g. "host=localhost user=postgres dbname=bench sslmode=disable".
+func BenchmarkGetTablesWithDependencies(b *testing.B) {
+	dsn := os.Getenv("DB_FAKER_BENCH_DSN")
+	if dsn == "" {
+		b.Skip("DB_FAKER_BENCH_DSN is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	var ddl strings.Builder
+	ddl.WriteString("DROP SCHEMA IF EXISTS db_faker_bench CASCADE; CREATE SCHEMA db_faker_bench;")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&ddl, `CREATE TABLE db_faker_bench.table_%04d (
+			id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			parent_id bigint`, i)
+		if i > 0 {
+			fmt.Fprintf(&ddl, " REFERENCES db_faker_bench.table_%04d (id)", i-1)
+		}
+		ddl.WriteString(`,
+			code varchar(32) NOT NULL UNIQUE,
+			amount numeric(12,2) CHECK (amount >= 0),
+			created_at timestamp DEFAULT now());`)
+	}
+	if _, err := db.Exec(ddl.String()); err != nil {
+		b.Fatal(err)
+	}
+	defer db.Exec("DROP SCHEMA db_faker_bench CASCADE")
+
+	filter := SchemaFilter{Include: []string{"db_faker_bench"}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tables, err := GetTablesWithDependencies(db, filter)
+		if err != nil || len(tables) != 1000 {
+			b.Fatal(len(tables), err)
+		}
+	}
+}
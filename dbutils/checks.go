@@ -0,0 +1,509 @@
+package dbutils
+
+import (
+	"fmt"
+	"github.com/samber/mo"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// checkRetryLimit - how many times a generator is called to get a value allowed by CHECK constraints
+const checkRetryLimit = 100
+
+// CheckConstraint - CHECK constraint of a table
+type CheckConstraint struct {
+	Name       string `json:"name" yaml:"name"`
+	Definition string `json:"definition" yaml:"definition"` // e.g. CHECK ((quantity > 0))
+}
+
+// ColumnCheck - restrictions on column values parsed from CHECK constraints
+type ColumnCheck struct {
+	Min       mo.Option[float64]
+	MinStrict bool // Min itself is not allowed
+	Max       mo.Option[float64]
+	MaxStrict bool // Max itself is not allowed
+	MinLen    mo.Option[int]
+	MaxLen    mo.Option[int]
+	OneOf     []string
+}
+
+var (
+	whitespacePattern = regexp.MustCompile(`\s+`)
+	castPattern       = regexp.MustCompile(
+		`::\s*(character varying|double precision|bit varying|(timestamp|time)( with(out)? time zone)?|"?[\w.]+"?)(\(\d+(,\s*\d+)?\))?(\[\])?`)
+	parenAtomPattern = regexp.MustCompile(`(^|[^\w])\(\s*("(?:[^"]|"")+"|[A-Za-z_]\w*|'(?:[^']|'')*'|-?[\d.]+)\s*\)`)
+	betweenPattern   = regexp.MustCompile(
+		`(?i)("(?:[^"]|"")+"|[A-Za-z_]\w*)\s+BETWEEN\s+('(?:[^']|'')*'|-?[\d.]+)\s+AND\s+('(?:[^']|'')*'|-?[\d.]+)`)
+	comparisonPattern = regexp.MustCompile(
+		`^("(?:[^"]|"")+"|[A-Za-z_]\w*)\s*(>=|<=|=|>|<)\s*('(?:[^']|'')*'|-?[\d.]+)$`)
+	reversedComparisonPattern = regexp.MustCompile(
+		`^('(?:[^']|'')*'|-?[\d.]+)\s*(>=|<=|=|>|<)\s*("(?:[^"]|"")+"|[A-Za-z_]\w*)$`)
+	lengthPattern = regexp.MustCompile(
+		`(?i)^(?:length|char_length|character_length)\(\s*("(?:[^"]|"")+"|[A-Za-z_]\w*)\s*\)\s*(>=|<=|=|>|<)\s*(\d+)$`)
+	inPattern       = regexp.MustCompile(`(?i)^("(?:[^"]|"")+"|[A-Za-z_]\w*)\s+IN\s*\(?(.+?)\)?$`)
+	anyArrayPattern = regexp.MustCompile(
+		`(?i)^("(?:[^"]|"")+"|[A-Za-z_]\w*)\s*=\s*ANY\s*\(\s*\(?\s*ARRAY\s*\[(.+)\]\s*\)?\s*\)$`)
+	notNullPattern = regexp.MustCompile(`(?i)^("(?:[^"]|"")+"|[A-Za-z_]\w*)\s+IS\s+NOT\s+NULL$`)
+)
+
+// ParseCheckConstraint parses CHECK constraint definition into restrictions on columns.
+// Conditions which can't be parsed are returned as the second value, parsed ones are still applied.
+func ParseCheckConstraint(definition string) (map[string]ColumnCheck, []string) {
+	expr := strings.TrimSpace(whitespacePattern.ReplaceAllString(definition, " "))
+	if len(expr) >= 5 && strings.EqualFold(expr[:5], "CHECK") {
+		expr = strings.TrimSpace(expr[5:])
+	}
+	expr = strings.TrimSuffix(expr, "NOT VALID")
+	expr = castPattern.ReplaceAllString(expr, "")
+	for {
+		replaced := parenAtomPattern.ReplaceAllString(expr, "$1$2")
+		if replaced == expr {
+			break
+		}
+		expr = replaced
+	}
+	expr = betweenPattern.ReplaceAllString(expr, "($1 >= $2) AND ($1 <= $3)")
+
+	checks := make(map[string]ColumnCheck)
+	unparsed := make([]string, 0)
+	for _, part := range splitTopLevelAnd(stripParens(expr)) {
+		part = stripParens(part)
+		colName, check, ok := parseCondition(part)
+		if !ok {
+			unparsed = append(unparsed, part)
+			continue
+		}
+		if colName == "" {
+			continue
+		}
+		checks[colName] = checks[colName].Merge(check)
+	}
+	return checks, unparsed
+}
+
+func parseCondition(cond string) (string, ColumnCheck, bool) {
+	var check ColumnCheck
+	if m := comparisonPattern.FindStringSubmatch(cond); m != nil {
+		return applyComparison(unquoteIdent(m[1]), m[2], m[3])
+	}
+	if m := reversedComparisonPattern.FindStringSubmatch(cond); m != nil {
+		return applyComparison(unquoteIdent(m[3]), flipOperator(m[2]), m[1])
+	}
+	if m := lengthPattern.FindStringSubmatch(cond); m != nil {
+		n, _ := strconv.Atoi(m[3])
+		switch m[2] {
+		case ">=":
+			check.MinLen = mo.Some(n)
+		case ">":
+			check.MinLen = mo.Some(n + 1)
+		case "<=":
+			check.MaxLen = mo.Some(n)
+		case "<":
+			check.MaxLen = mo.Some(n - 1)
+		case "=":
+			check.MinLen = mo.Some(n)
+			check.MaxLen = mo.Some(n)
+		}
+		return unquoteIdent(m[1]), check, true
+	}
+	if m := anyArrayPattern.FindStringSubmatch(cond); m != nil {
+		values, ok := parseLiteralList(m[2])
+		check.OneOf = values
+		return unquoteIdent(m[1]), check, ok
+	}
+	if m := inPattern.FindStringSubmatch(cond); m != nil {
+		values, ok := parseLiteralList(m[2])
+		check.OneOf = values
+		return unquoteIdent(m[1]), check, ok
+	}
+	if notNullPattern.MatchString(cond) {
+		// NOT NULL is already known from the column definition
+		return "", check, true
+	}
+	return "", check, false
+}
+
+func applyComparison(colName, op, literal string) (string, ColumnCheck, bool) {
+	var check ColumnCheck
+	value := unquoteLiteral(literal)
+	if op == "=" {
+		check.OneOf = []string{value}
+		return colName, check, true
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "", check, false
+	}
+	switch op {
+	case ">=":
+		check.Min = mo.Some(n)
+	case ">":
+		check.Min = mo.Some(n)
+		check.MinStrict = true
+	case "<=":
+		check.Max = mo.Some(n)
+	case "<":
+		check.Max = mo.Some(n)
+		check.MaxStrict = true
+	}
+	return colName, check, true
+}
+
+func flipOperator(op string) string {
+	switch op {
+	case ">=":
+		return "<="
+	case ">":
+		return "<"
+	case "<=":
+		return ">="
+	case "<":
+		return ">"
+	}
+	return op
+}
+
+func parseLiteralList(s string) ([]string, bool) {
+	values := make([]string, 0)
+	for _, item := range splitTopLevel(s, ",") {
+		item = stripParens(strings.TrimSpace(item))
+		if !strings.HasPrefix(item, "'") {
+			if _, err := strconv.ParseFloat(item, 64); err != nil {
+				return nil, false
+			}
+		}
+		values = append(values, unquoteLiteral(item))
+	}
+	return values, len(values) > 0
+}
+
+func unquoteLiteral(s string) string {
+	if len(s) >= 2 && strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'") {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+func unquoteIdent(s string) string {
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return strings.ReplaceAll(s[1:len(s)-1], `""`, `"`)
+	}
+	return strings.ToLower(s)
+}
+
+// stripParens removes parentheses wrapping the whole expression
+func stripParens(s string) string {
+	s = strings.TrimSpace(s)
+	for len(s) >= 2 && s[0] == '(' && closingParen(s, 0) == len(s)-1 {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// closingParen returns index of parenthesis closing the one at index open, or -1
+func closingParen(s string, open int) int {
+	depth := 0
+	inString := false
+	for i := open; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			inString = !inString
+		case inString:
+		case s[i] == '(':
+			depth++
+		case s[i] == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func splitTopLevelAnd(s string) []string {
+	return splitTopLevel(s, " AND ")
+}
+
+// splitTopLevel splits s by sep (case-insensitive) outside of parentheses and string literals
+func splitTopLevel(s, sep string) []string {
+	parts := make([]string, 0)
+	depth := 0
+	inString := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			inString = !inString
+		case inString:
+		case s[i] == '(' || s[i] == '[':
+			depth++
+		case s[i] == ')' || s[i] == ']':
+			depth--
+		case depth == 0 && i+len(sep) <= len(s) && strings.EqualFold(s[i:i+len(sep)], sep):
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// Merge combines two restrictions, the result allows only values allowed by both
+func (c ColumnCheck) Merge(other ColumnCheck) ColumnCheck {
+	if v, ok := other.Min.Get(); ok {
+		cur, present := c.Min.Get()
+		if !present || v > cur || (v == cur && other.MinStrict) {
+			c.Min = other.Min
+			c.MinStrict = other.MinStrict
+		}
+	}
+	if v, ok := other.Max.Get(); ok {
+		cur, present := c.Max.Get()
+		if !present || v < cur || (v == cur && other.MaxStrict) {
+			c.Max = other.Max
+			c.MaxStrict = other.MaxStrict
+		}
+	}
+	if v, ok := other.MinLen.Get(); ok && v > c.MinLen.OrElse(-1) {
+		c.MinLen = other.MinLen
+	}
+	if v, ok := other.MaxLen.Get(); ok && (c.MaxLen.IsAbsent() || v < c.MaxLen.MustGet()) {
+		c.MaxLen = other.MaxLen
+	}
+	if len(other.OneOf) > 0 {
+		if len(c.OneOf) == 0 {
+			c.OneOf = other.OneOf
+		} else {
+			common := make([]string, 0)
+			for _, v := range c.OneOf {
+				for _, o := range other.OneOf {
+					if v == o {
+						common = append(common, v)
+						break
+					}
+				}
+			}
+			c.OneOf = common
+		}
+	}
+	return c
+}
+
+// IsEmpty reports whether the check has no restrictions
+func (c ColumnCheck) IsEmpty() bool {
+	return c.Min.IsAbsent() && c.Max.IsAbsent() && c.MinLen.IsAbsent() && c.MaxLen.IsAbsent() && len(c.OneOf) == 0
+}
+
+// Allows reports whether value satisfies the check
+func (c ColumnCheck) Allows(value string) bool {
+	if len(c.OneOf) > 0 {
+		found := false
+		for _, v := range c.OneOf {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	length := utf8.RuneCountInString(value)
+	if n, ok := c.MinLen.Get(); ok && length < n {
+		return false
+	}
+	if n, ok := c.MaxLen.Get(); ok && length > n {
+		return false
+	}
+	if c.Min.IsPresent() || c.Max.IsPresent() {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		if m, ok := c.Min.Get(); ok && (n < m || (c.MinStrict && n == m)) {
+			return false
+		}
+		if m, ok := c.Max.Get(); ok && (n > m || (c.MaxStrict && n == m)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter wraps gen so it retries values not allowed by the check
+func (c ColumnCheck) Filter(gen func() string) func() string {
+	if c.IsEmpty() {
+		return gen
+	}
+	return func() string {
+		val := gen()
+		for i := 0; i < checkRetryLimit && !c.Allows(val); i++ {
+			val = gen()
+		}
+		return val
+	}
+}
+
+// Narrow returns default generator of dataType producing only values allowed by the check
+func (c ColumnCheck) Narrow(dataType DataType, gen func() string) func() string {
+	if domain, ok := dataType.(Domain); ok {
+		c = c.Merge(domain.Check)
+		dataType = domain.Base
+		gen = domain.Base.DefaultGenerator()
+	}
+	if c.IsEmpty() {
+		return gen
+	}
+	if len(c.OneOf) > 0 {
+		allowed := make([]string, 0, len(c.OneOf))
+		for _, v := range c.OneOf {
+			if c.Allows(v) {
+				allowed = append(allowed, v)
+			}
+		}
+		if len(allowed) == 0 {
+			return gen
+		}
+		return func() string {
+			return allowed[rand.Intn(len(allowed))]
+		}
+	}
+	switch t := dataType.(type) {
+	case Int, BigInt, SmallInt:
+		if c.Min.IsPresent() || c.Max.IsPresent() {
+			lo, hi := c.intRange(0, 999)
+			return c.Filter(func() string {
+				return strconv.FormatInt(lo+rand.Int63n(hi-lo+1), 10)
+			})
+		}
+	case Numeric:
+		if c.Min.IsPresent() || c.Max.IsPresent() {
+			lo, hi := c.floatRange(0, min(1000, t.maxValue()))
+			return c.Filter(func() string {
+				return t.format(hi - (hi-lo)*rand.Float64())
+			})
+		}
+	case Float8, Real, Money:
+		if c.Min.IsPresent() || c.Max.IsPresent() {
+			lo, hi := c.floatRange(0, 1000)
+			return c.Filter(func() string {
+				return fmt.Sprintf("%f", hi-(hi-lo)*rand.Float64())
+			})
+		}
+	case VarChar, Char, Text:
+		if c.MinLen.IsPresent() || c.MaxLen.IsPresent() {
+			return c.Filter(func() string {
+				return fitLength(gen(), c.MinLen.OrElse(0), c.MaxLen.OrElse(math.MaxInt))
+			})
+		}
+	}
+	return c.Filter(gen)
+}
+
+// intRange narrows default range [defLo, defHi] to the check bounds
+func (c ColumnCheck) intRange(defLo, defHi int64) (int64, int64) {
+	var minOpt, maxOpt mo.Option[int64]
+	if m, ok := c.Min.Get(); ok {
+		lo := int64(math.Ceil(m))
+		if c.MinStrict && float64(lo) == m {
+			lo++
+		}
+		minOpt = mo.Some(lo)
+	}
+	if m, ok := c.Max.Get(); ok {
+		hi := int64(math.Floor(m))
+		if c.MaxStrict && float64(hi) == m {
+			hi--
+		}
+		maxOpt = mo.Some(hi)
+	}
+	return narrowRange(defLo, defHi, minOpt, maxOpt)
+}
+
+// floatRange narrows default range [defLo, defHi] to the check bounds
+func (c ColumnCheck) floatRange(defLo, defHi float64) (float64, float64) {
+	var minOpt, maxOpt mo.Option[float64]
+	if m, ok := c.Min.Get(); ok {
+		minOpt = mo.Some(m)
+	}
+	if m, ok := c.Max.Get(); ok {
+		maxOpt = mo.Some(m)
+	}
+	return narrowRange(defLo, defHi, minOpt, maxOpt)
+}
+
+func narrowRange[T int64 | float64](defLo, defHi T, minOpt, maxOpt mo.Option[T]) (T, T) {
+	lo, hi := defLo, defHi
+	minV, hasMin := minOpt.Get()
+	maxV, hasMax := maxOpt.Get()
+	if hasMin && minV > lo {
+		lo = minV
+	}
+	if hasMax && maxV < hi {
+		hi = maxV
+	}
+	if lo > hi {
+		switch {
+		case hasMin && hasMax:
+			lo, hi = minV, maxV
+		case hasMin:
+			hi = lo + (defHi - defLo)
+		default:
+			lo = hi - (defHi - defLo)
+		}
+	}
+	if lo > hi {
+		return lo, lo
+	}
+	return lo, hi
+}
+
+// fitLength truncates or pads s to have length between minLen and maxLen runes
+func fitLength(s string, minLen, maxLen int) string {
+	runes := []rune(s)
+	if len(runes) > maxLen {
+		runes = runes[:maxLen]
+	}
+	for len(runes) < minLen {
+		runes = append(runes, rune('a'+rand.Intn(26)))
+	}
+	return string(runes)
+}
+
+// applyCheckConstraints narrows default generators of table columns to its CHECK constraints
+// and returns warnings about conditions which can't be parsed
+func applyCheckConstraints(table *Table) []string {
+	warnings := make([]string, 0)
+	for _, constraint := range table.Checks {
+		checks, unparsed := ParseCheckConstraint(constraint.Definition)
+		for colName, check := range checks {
+			col, ok := table.Columns[colName]
+			if !ok {
+				unparsed = append(unparsed, "unknown column "+colName)
+				continue
+			}
+			col.Check = col.Check.Merge(check)
+			table.Columns[colName] = col
+		}
+		if len(unparsed) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: CHECK constraint %s can't be fully parsed (%s)",
+				table.QualifiedName(), constraint.Name, strings.Join(unparsed, "; ")))
+		}
+	}
+	for colName, col := range table.Columns {
+		if domain, ok := col.DataType.(Domain); ok && len(domain.Unparsed) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s.%s: CHECK constraint of domain %s can't be fully parsed (%s)",
+				table.QualifiedName(), colName, domain.Name, strings.Join(domain.Unparsed, "; ")))
+		}
+		if !col.Check.IsEmpty() {
+			col.DataGen = col.Check.Narrow(col.DataType, col.DataGen)
+			table.Columns[colName] = col
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,74 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestParseCheckConstraint(t *testing.T) {
+	checks, unparsed := ParseCheckConstraint("CHECK ((stock_quantity >= 0))")
+	assert.Empty(t, unparsed)
+	assert.Equal(t, 0.0, checks["stock_quantity"].Min.OrElse(-1))
+	assert.False(t, checks["stock_quantity"].MinStrict)
+
+	checks, unparsed = ParseCheckConstraint("CHECK (quantity > 0)")
+	assert.Empty(t, unparsed)
+	assert.True(t, checks["quantity"].MinStrict)
+
+	checks, unparsed = ParseCheckConstraint("CHECK (((rating >= 1) AND (rating <= 5)))")
+	assert.Empty(t, unparsed)
+	assert.Equal(t, 1.0, checks["rating"].Min.OrElse(0))
+	assert.Equal(t, 5.0, checks["rating"].Max.OrElse(0))
+
+	checks, unparsed = ParseCheckConstraint("CHECK (discount BETWEEN 0 AND 50 AND 100 > price)")
+	assert.Empty(t, unparsed)
+	assert.Equal(t, 50.0, checks["discount"].Max.OrElse(0))
+	assert.Equal(t, 100.0, checks["price"].Max.OrElse(0))
+	assert.True(t, checks["price"].MaxStrict)
+
+	checks, unparsed = ParseCheckConstraint(
+		"CHECK (((status)::text = ANY ((ARRAY['new'::character varying, 'paid'::character varying])::text[])))")
+	assert.Empty(t, unparsed)
+	assert.Equal(t, []string{"new", "paid"}, checks["status"].OneOf)
+
+	checks, unparsed = ParseCheckConstraint("CHECK (kind IN ('a', 'b''c'))")
+	assert.Empty(t, unparsed)
+	assert.Equal(t, []string{"a", "b'c"}, checks["kind"].OneOf)
+
+	checks, unparsed = ParseCheckConstraint("CHECK (kind IN ('a'))")
+	assert.Empty(t, unparsed)
+	assert.Equal(t, []string{"a"}, checks["kind"].OneOf)
+
+	checks, unparsed = ParseCheckConstraint("CHECK ((length((name)::text) <= 50))")
+	assert.Empty(t, unparsed)
+	assert.Equal(t, 50, checks["name"].MaxLen.OrElse(0))
+
+	checks, unparsed = ParseCheckConstraint("CHECK (((end_date > start_date) AND (price > (0)::numeric)))")
+	assert.Equal(t, []string{"end_date > start_date"}, unparsed)
+	assert.Equal(t, 0.0, checks["price"].Min.OrElse(-1))
+}
+
+func TestColumnCheck_Narrow(t *testing.T) {
+	checks, _ := ParseCheckConstraint("CHECK (quantity > 0 AND quantity <= 3)")
+	gen := checks["quantity"].Narrow(Int{}, Int{}.DefaultGenerator())
+	for i := 0; i < 100; i++ {
+		n, err := strconv.Atoi(gen())
+		assert.NoError(t, err)
+		assert.True(t, n >= 1 && n <= 3)
+	}
+
+	checks, _ = ParseCheckConstraint("CHECK (amount >= 5000)")
+	gen = checks["amount"].Narrow(Numeric{}, Numeric{}.DefaultGenerator())
+	for i := 0; i < 100; i++ {
+		n, err := strconv.ParseFloat(gen(), 64)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, n, 5000.0)
+	}
+
+	checks, _ = ParseCheckConstraint("CHECK (char_length(code) >= 3 AND char_length(code) <= 5)")
+	gen = checks["code"].Narrow(Text{}, Text{}.DefaultGenerator())
+	for i := 0; i < 100; i++ {
+		assert.True(t, checks["code"].Allows(gen()))
+	}
+}
@@ -3,12 +3,13 @@ package dbutils
 import (
 	"fmt"
 	"github.com/go-faker/faker/v4"
-	"github.com/go-faker/faker/v4/pkg/options"
 	"github.com/samber/mo"
 	funcutil "github.com/victornguen/db-faker/common"
 	"github.com/victornguen/db-faker/datagen"
+	"math"
 	"math/rand"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -16,6 +17,16 @@ type DataType interface {
 	DefaultGenerator() func() string
 }
 
+// Array - array of elements of another type
+// aliases: type[], ARRAY
+type Array struct {
+	Elem DataType
+}
+
+func (a Array) DefaultGenerator() func() string {
+	return datagen.ArrayGenerator(a.Elem.DefaultGenerator(), datagen.DefaultArrayMinLen, datagen.DefaultArrayMaxLen)
+}
+
 // BigInt - signed eight-byte integer
 // aliases:int8
 type BigInt struct{}
@@ -46,9 +57,10 @@ type Bit struct {
 }
 
 func (b Bit) DefaultGenerator() func() string {
-	val := rand.Intn(2)
+	// bit without length is bit(1)
+	length := b.Len.OrElse(1)
 	return func() string {
-		return fmt.Sprintf("%d", val)
+		return randomBits(length)
 	}
 }
 
@@ -64,12 +76,21 @@ func (v VarBit) DefaultGenerator() func() string {
 	if !present {
 		length = 8
 		return func() string {
-			return fmt.Sprintf("%08b", rand.Intn(256))
+			return randomBits(length)
 		}
 	}
 	return func() string {
-		return fmt.Sprintf("%0*b", length, rand.Intn(1<<length))
+		return randomBits(1 + rand.Intn(length))
+	}
+}
+
+// randomBits returns random bit string of n bits
+func randomBits(n int) string {
+	bits := make([]byte, n)
+	for i := range bits {
+		bits[i] = byte('0' + rand.Intn(2))
 	}
+	return string(bits)
 }
 
 // Boolean - logical Boolean (true/false)
@@ -113,9 +134,7 @@ func (c Char) DefaultGenerator() func() string {
 		length = 1
 	}
 	return func() string {
-		return faker.Word(
-			options.WithRandomStringLength(uint(length)),
-		)
+		return fitLength(faker.Word(), 0, length)
 	}
 }
 
@@ -128,12 +147,12 @@ type VarChar struct {
 func (v VarChar) DefaultGenerator() func() string {
 	maxLen, present := v.MaxLen.Get()
 	if !present || maxLen < 1 {
-		maxLen = 255
+		return func() string {
+			return faker.Sentence()
+		}
 	}
 	return func() string {
-		return faker.Sentence(
-			options.WithRandomStringLength(uint(maxLen)),
-		)
+		return strings.TrimSpace(fitLength(faker.Sentence(), 0, maxLen))
 	}
 }
 
@@ -155,6 +174,67 @@ func (c Circle) DefaultGenerator() func() string {
 	}
 }
 
+// CompositeAttribute - attribute of composite type
+type CompositeAttribute struct {
+	Name     string
+	DataType DataType
+}
+
+// Composite - user-defined composite (row) type
+type Composite struct {
+	Name       string // qualified type name
+	Attributes []CompositeAttribute
+}
+
+func (c Composite) DefaultGenerator() func() string {
+	return c.Generator(nil)
+}
+
+// Generator returns generator of row literals. Generators in overrides replace default generators of attributes,
+// keys are attribute names or paths like "geo.lat" for attributes of nested composite types.
+func (c Composite) Generator(overrides map[string]func() string) func() string {
+	gens := make([]func() string, len(c.Attributes))
+	for i, attr := range c.Attributes {
+		if gen, ok := overrides[attr.Name]; ok {
+			gens[i] = gen
+			continue
+		}
+		nested := make(map[string]func() string)
+		for path, gen := range overrides {
+			if rest, ok := strings.CutPrefix(path, attr.Name+"."); ok {
+				nested[rest] = gen
+			}
+		}
+		if composite, ok := attr.DataType.(Composite); ok && len(nested) > 0 {
+			gens[i] = composite.Generator(nested)
+		} else {
+			gens[i] = attr.DataType.DefaultGenerator()
+		}
+	}
+	return func() string {
+		values := make([]string, len(gens))
+		for i, gen := range gens {
+			values[i] = gen()
+		}
+		return datagen.RowLiteral(values)
+	}
+}
+
+// HasAttribute reports whether path (e.g. "city" or "geo.lat") names an attribute
+func (c Composite) HasAttribute(path string) bool {
+	for _, attr := range c.Attributes {
+		if attr.Name == path {
+			return true
+		}
+		if rest, ok := strings.CutPrefix(path, attr.Name+"."); ok {
+			if composite, ok := attr.DataType.(Composite); ok {
+				return composite.HasAttribute(rest)
+			}
+		}
+	}
+	return false
+}
+
 // Date - calendar date (year, month, day)
 type Date struct{}
 
@@ -164,6 +244,69 @@ func (d Date) DefaultGenerator() func() string {
 	}
 }
 
+// Domain - user-defined domain, base type with optional CHECK constraints
+type Domain struct {
+	Name     string // qualified type name
+	Base     DataType
+	Checks   []CheckConstraint // CHECK constraints of the domain and the domains it is based on
+	Check    ColumnCheck       // parsed CHECK constraints of the domain
+	Unparsed []string          // CHECK conditions which can't be parsed
+}
+
+// NewDomain creates domain over base type, checks refer to the domain value as VALUE.
+// Domain over another domain is flattened, so Base is never a Domain.
+func NewDomain(name string, base DataType, checks []CheckConstraint) Domain {
+	domain := Domain{Name: name, Base: base, Checks: make([]CheckConstraint, 0), Unparsed: make([]string, 0)}
+	if baseDomain, ok := base.(Domain); ok {
+		domain.Base = baseDomain.Base
+		domain.Checks = append(domain.Checks, baseDomain.Checks...)
+		domain.Check = baseDomain.Check
+		domain.Unparsed = append(domain.Unparsed, baseDomain.Unparsed...)
+	}
+	domain.Checks = append(domain.Checks, checks...)
+	for _, constraint := range checks {
+		parsed, unparsed := ParseCheckConstraint(constraint.Definition)
+		for name, check := range parsed {
+			if name == "value" {
+				domain.Check = domain.Check.Merge(check)
+			} else {
+				unparsed = append(unparsed, "unknown column "+name)
+			}
+		}
+		domain.Unparsed = append(domain.Unparsed, unparsed...)
+	}
+	return domain
+}
+
+func (d Domain) DefaultGenerator() func() string {
+	return d.Check.Narrow(d.Base, d.Base.DefaultGenerator())
+}
+
+// Enum - user-defined enumerated type
+type Enum struct {
+	Name   string // qualified type name
+	Labels []string
+}
+
+func (e Enum) DefaultGenerator() func() string {
+	return func() string {
+		if len(e.Labels) == 0 {
+			return ""
+		}
+		return e.Labels[rand.Intn(len(e.Labels))]
+	}
+}
+
+// HasLabel reports whether label is one of enum values
+func (e Enum) HasLabel(label string) bool {
+	for _, l := range e.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
 // Float8 - double precision floating-point number (8 bytes)
 // aliases:float8, double precision
 type Float8 struct{}
@@ -286,9 +429,45 @@ type Numeric struct {
 }
 
 func (n Numeric) DefaultGenerator() func() string {
+	maxValue := min(1000, n.maxValue())
 	return func() string {
-		return fmt.Sprintf("%f", rand.Float64()*1000)
+		return n.format(rand.Float64() * maxValue)
+	}
+}
+
+// maxValue returns the largest value allowed by precision and scale
+func (n Numeric) maxValue() float64 {
+	precision, present := n.Precision.Get()
+	if !present {
+		return math.MaxFloat64
+	}
+	scale := n.Scale.OrElse(0)
+	return math.Pow10(precision-scale) - math.Pow10(-scale)
+}
+
+// format formats v with scale digits after the point, rounding down so the value doesn't exceed maxValue
+func (n Numeric) format(v float64) string {
+	scale, present := n.Scale.Get()
+	if !present {
+		if n.Precision.IsPresent() {
+			// numeric(p) has scale 0
+			return strconv.FormatFloat(math.Trunc(v), 'f', 0, 64)
+		}
+		return fmt.Sprintf("%f", v)
 	}
+	factor := math.Pow10(scale)
+	return strconv.FormatFloat(math.Trunc(v*factor)/factor, 'f', scale, 64)
+}
+
+// digits returns the number of digits after the point written by format
+func (n Numeric) digits() int {
+	if scale, present := n.Scale.Get(); present {
+		return scale
+	}
+	if n.Precision.IsPresent() {
+		return 0
+	}
+	return 6
 }
 
 // Path - geometric path on a plane
@@ -440,6 +619,18 @@ func (u UUID) DefaultGenerator() func() string {
 	}
 }
 
+// Unsupported - type without a generator, e.g. citext or geometry.
+// Columns of it are written by rules, otherwise they are left to their default or NULL, see ApplyRulesToTables.
+type Unsupported struct {
+	Name string
+}
+
+func (u Unsupported) DefaultGenerator() func() string {
+	return func() string {
+		return ""
+	}
+}
+
 // XML - XML data
 type XML struct{}
 
@@ -450,6 +641,13 @@ func (x XML) DefaultGenerator() func() string {
 }
 
 func StringToDataType(s string) (DataType, error) {
+	if elemType, isArray := strings.CutSuffix(strings.TrimSpace(s), "[]"); isArray {
+		elem, err := StringToDataType(elemType)
+		if err != nil {
+			return nil, err
+		}
+		return Array{Elem: elem}, nil
+	}
 	normalizedType := NormalizeType(s)
 	matches := extractMatches(normalizedType)
 	if len(matches) == 0 {
@@ -464,8 +662,9 @@ func StringToDataType(s string) (DataType, error) {
 	return createDataType(typeName, n, m, additive)
 }
 
+var typePattern = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_]*)\s*(\((\d+)(,\s*(\d+))?\))?\s*([a-zA-Z\s]+)?`)
+
 func extractMatches(s string) []string {
-	typePattern := regexp.MustCompile(`([a-zA-Z]+)\s*(\((\d+)(,\s*(\d+))?\))?\s*([a-zA-Z\s]+)?`)
 	return typePattern.FindStringSubmatch(s)
 }
 
@@ -504,9 +703,9 @@ func createDataType(typeName string, n, m mo.Option[int], additive mo.Option[str
 	case "bigserial", "serial8":
 		return BigSerial{}, nil
 	case "bit":
-		return Bit{}, nil
+		return Bit{Len: n}, nil
 	case "varbit":
-		return VarBit{}, nil
+		return VarBit{Len: n}, nil
 	case "boolean", "bool":
 		return Boolean{}, nil
 	case "box":
@@ -621,3 +820,118 @@ func NormalizeType(s string) string {
 	res = strings.ReplaceAll(res, "double precision", "float8")
 	return res
 }
+
+// TypeName returns name of the type which StringToDataType converts back to dataType,
+// user-defined types are named by their qualified name
+func TypeName(dataType DataType) string {
+	withLength := func(name string, n mo.Option[int]) string {
+		if v, ok := n.Get(); ok {
+			return fmt.Sprintf("%s(%d)", name, v)
+		}
+		return name
+	}
+	withZone := func(name string, precision mo.Option[int], withTimeZone bool) string {
+		name = withLength(name, precision)
+		if withTimeZone {
+			return name + " with time zone"
+		}
+		return name + " without time zone"
+	}
+	switch t := dataType.(type) {
+	case Array:
+		return TypeName(t.Elem) + "[]"
+	case Composite:
+		return t.Name
+	case Domain:
+		return t.Name
+	case Enum:
+		return t.Name
+	case BigInt:
+		return "bigint"
+	case BigSerial:
+		return "bigserial"
+	case Bit:
+		return withLength("bit", t.Len)
+	case VarBit:
+		return withLength("varbit", t.Len)
+	case Boolean:
+		return "boolean"
+	case Box:
+		return "box"
+	case ByteA:
+		return "bytea"
+	case Char:
+		return withLength("char", t.Len)
+	case VarChar:
+		return withLength("varchar", t.MaxLen)
+	case Cidr:
+		return "cidr"
+	case Circle:
+		return "circle"
+	case Date:
+		return "date"
+	case Float8:
+		return "float8"
+	case Inet:
+		return "inet"
+	case Int:
+		return "integer"
+	case Interval:
+		return "interval"
+	case JSON:
+		return "json"
+	case JsonB:
+		return "jsonb"
+	case Line:
+		return "line"
+	case LSeg:
+		return "lseg"
+	case MacAddr:
+		return "macaddr"
+	case MacAddr8:
+		return "macaddr8"
+	case Money:
+		return "money"
+	case Numeric:
+		if p, ok := t.Precision.Get(); ok {
+			return fmt.Sprintf("numeric(%d,%d)", p, t.Scale.OrElse(0))
+		}
+		return "numeric"
+	case Path:
+		return "path"
+	case PgLsn:
+		return "pg_lsn"
+	case PgSnapshot:
+		return "pg_snapshot"
+	case Point:
+		return "point"
+	case Polygon:
+		return "polygon"
+	case Real:
+		return "real"
+	case SmallInt:
+		return "smallint"
+	case SmallSerial:
+		return "smallserial"
+	case Serial:
+		return "serial"
+	case Text:
+		return "text"
+	case Time:
+		return withZone("time", t.Precision, t.WithTimeZone)
+	case TimeStamp:
+		return withZone("timestamp", t.Precision, t.WithTimeZone)
+	case TsQuery:
+		return "tsquery"
+	case TsVector:
+		return "tsvector"
+	case UUID:
+		return "uuid"
+	case XML:
+		return "xml"
+	case Unsupported:
+		return t.Name
+	default:
+		return fmt.Sprintf("%T", dataType)
+	}
+}
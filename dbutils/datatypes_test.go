@@ -3,8 +3,10 @@ package dbutils
 import (
 	"github.com/stretchr/testify/assert"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
 func TestStringToDataType(t *testing.T) {
@@ -87,6 +89,35 @@ func TestVarChar_DefaultGenerator(t *testing.T) {
 		t.Error("Generator is nil")
 	}
 	assert.NotEmpty(t, gen())
+
+	limited, err := StringToDataType("character varying(10)")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, limited.(VarChar).MaxLen.OrElse(0))
+	gen = limited.DefaultGenerator()
+	for i := 0; i < 100; i++ {
+		assert.LessOrEqual(t, utf8.RuneCountInString(gen()), 10)
+	}
+}
+
+func TestNumeric_DefaultGenerator(t *testing.T) {
+	n, err := StringToDataType("numeric(4,2)")
+	assert.NoError(t, err)
+	gen := n.DefaultGenerator()
+	for i := 0; i < 100; i++ {
+		v := gen()
+		f, err := strconv.ParseFloat(v, 64)
+		assert.NoError(t, err)
+		assert.Less(t, f, 100.0)
+		_, decimals, _ := strings.Cut(v, ".")
+		assert.Len(t, decimals, 2)
+	}
+}
+
+func TestBit_DefaultGenerator(t *testing.T) {
+	b, err := StringToDataType("bit(8)")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[01]{8}$`, b.DefaultGenerator()())
+	assert.Regexp(t, `^[01]$`, Bit{}.DefaultGenerator()())
 }
 
 func TestDate_DefaultGenerator(t *testing.T) {
@@ -127,3 +158,74 @@ func TestPolygon_DefaultGenerator(t *testing.T) {
 	}
 	assert.NotEmpty(t, gen())
 }
+
+func TestArray_DefaultGenerator(t *testing.T) {
+	dt, err := StringToDataType("character varying(10)[]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, ok := dt.(Array)
+	if !ok {
+		t.Fatalf("Invalid type %T", dt)
+	}
+	assert.Equal(t, 10, arr.Elem.(VarChar).MaxLen.OrElse(0))
+
+	result := Array{Elem: Int{}}.DefaultGenerator()()
+	assert.True(t, strings.HasPrefix(result, "{") && strings.HasSuffix(result, "}"))
+}
+
+func TestDomain_DefaultGenerator(t *testing.T) {
+	postal := NewDomain("public.us_postal", Text{}, []CheckConstraint{
+		{Name: "us_postal_check", Definition: "CHECK ((length(VALUE) = 5))"},
+		{Name: "us_postal_format", Definition: "CHECK ((VALUE ~ '^\\d{5}$'::text))"},
+	})
+	assert.Equal(t, 5, postal.Check.MaxLen.OrElse(0))
+	assert.Len(t, postal.Unparsed, 1)
+
+	nested := NewDomain("public.zip", postal, nil)
+	assert.Equal(t, Text{}, nested.Base)
+
+	gen := nested.DefaultGenerator()
+	for i := 0; i < 20; i++ {
+		assert.Len(t, []rune(gen()), 5)
+	}
+}
+
+func TestComposite_Generator(t *testing.T) {
+	address := Composite{
+		Name: "public.address_t",
+		Attributes: []CompositeAttribute{
+			{Name: "street", DataType: Text{}},
+			{Name: "city", DataType: Text{}},
+			{Name: "geo", DataType: Composite{
+				Name:       "public.geo_t",
+				Attributes: []CompositeAttribute{{Name: "lat", DataType: Float8{}}, {Name: "lon", DataType: Float8{}}},
+			}},
+		},
+	}
+	assert.True(t, address.HasAttribute("city"))
+	assert.True(t, address.HasAttribute("geo.lat"))
+	assert.False(t, address.HasAttribute("geo.alt"))
+
+	gen := address.Generator(map[string]func() string{
+		"city":    func() string { return `New "York"` },
+		"geo.lat": func() string { return "1.5" },
+	})
+	result := gen()
+	assert.True(t, strings.HasPrefix(result, "(") && strings.HasSuffix(result, ")"))
+	assert.Contains(t, result, `"New \"York\""`)
+	assert.Contains(t, result, `\"1.5\"`)
+}
+
+func TestTypeName_RoundTrip(t *testing.T) {
+	for _, s := range []string{
+		"bigint", "double precision", "int4", "float4", "macaddr8", "pg_lsn", "varchar(20)", "bit varying(5)",
+		"numeric(6,2)", "time(3) with time zone", "timestamp without time zone", "integer[]",
+	} {
+		dataType, err := StringToDataType(s)
+		assert.NoError(t, err, s)
+		restored, err := StringToDataType(TypeName(dataType))
+		assert.NoError(t, err, s)
+		assert.Equal(t, dataType, restored, s)
+	}
+}
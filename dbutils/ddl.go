@@ -0,0 +1,1311 @@
+package dbutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const defaultDDLSchema = "public"
+
+// UnparsedStatement - DDL statement which is not applied to the schema model
+type UnparsedStatement struct {
+	File      string
+	Line      int
+	Statement string
+	Reason    string
+}
+
+func (s UnparsedStatement) String() string {
+	statement := whitespacePattern.ReplaceAllString(s.Statement, " ")
+	if len(statement) > 80 {
+		statement = statement[:77] + "..."
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", s.File, s.Line, s.Reason, statement)
+}
+
+// ParseDDL builds tables of schemas matching the filter from DDL: a .sql file, or a directory of migrations
+// applied in version order (golang-migrate *.up.sql files, goose files with -- +goose Up sections).
+// Statements which can't be parsed are skipped and returned with the reason.
+func ParseDDL(path string, filter SchemaFilter) ([]Table, []UnparsedStatement, error) {
+	files, err := ddlFiles(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	schema := newDDLSchema()
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		schema.apply(file, string(src))
+	}
+	return schema.tables(filter), schema.unparsed, nil
+}
+
+// ddlFiles returns the file itself, or .sql files of the directory except down migrations, in version order
+func ddlFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") || strings.HasSuffix(name, ".down.sql") {
+			continue
+		}
+		files = append(files, name)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .sql files found in %s", path)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		vi, vj := migrationVersion(files[i]), migrationVersion(files[j])
+		if len(vi) != len(vj) {
+			return len(vi) < len(vj)
+		}
+		if vi != vj {
+			return vi < vj
+		}
+		return files[i] < files[j]
+	})
+	for i, name := range files {
+		files[i] = filepath.Join(path, name)
+	}
+	return files, nil
+}
+
+// migrationVersion returns leading digits of migration file name without leading zeros
+func migrationVersion(name string) string {
+	end := 0
+	for end < len(name) && isDigit(name[end]) {
+		end++
+	}
+	return strings.TrimLeft(name[:end], "0")
+}
+
+// ddlSchema - schema model built by applying DDL statements
+type ddlSchema struct {
+	defaultSchema string
+	relations     []*ddlTable         // in creation order
+	types         map[string]DataType // user-defined types by qualified name
+	unparsed      []UnparsedStatement
+}
+
+// ddlTable - table being built from DDL
+type ddlTable struct {
+	table      Table
+	typeNames  map[string]string // names of column types, resolved again when all statements are applied
+	primaryKey []string          // primary key columns in definition order
+	pkName     string
+}
+
+func newDDLSchema() *ddlSchema {
+	return &ddlSchema{
+		defaultSchema: defaultDDLSchema,
+		relations:     make([]*ddlTable, 0),
+		types:         make(map[string]DataType),
+		unparsed:      make([]UnparsedStatement, 0),
+	}
+}
+
+// apply applies statements of the source, statements which can't be applied are added to unparsed
+func (s *ddlSchema) apply(file, src string) {
+	for _, stmt := range splitStatements(file, gooseUpSections(src)) {
+		if err := s.applyStatement(stmt.text); err != nil {
+			s.unparsed = append(s.unparsed, UnparsedStatement{
+				File: stmt.file, Line: stmt.line, Statement: stmt.text, Reason: err.Error(),
+			})
+		}
+	}
+}
+
+func (s *ddlSchema) applyStatement(text string) error {
+	p := newDDLParser(text)
+	switch {
+	case p.accept("create", "table"), p.accept("create", "unlogged", "table"):
+		return s.createTable(p)
+	case p.accept("create", "temporary", "table"), p.accept("create", "temp", "table"):
+		// temporary tables don't outlive the migration
+		return nil
+	case p.accept("create", "type"):
+		return s.createType(p)
+	case p.accept("create", "domain"):
+		return s.createDomain(p)
+	case p.accept("create", "unique", "index"):
+		return s.createUniqueIndex(p)
+	case p.accept("alter", "table"):
+		return s.alterTable(p)
+	case p.accept("alter", "type"):
+		return s.alterType(p)
+	case p.accept("alter", "domain"):
+		return s.alterDomain(p)
+	case p.accept("drop", "table"):
+		return s.dropTables(p)
+	case p.accept("drop", "type"), p.accept("drop", "domain"):
+		return s.dropTypes(p)
+	case p.accept("drop", "index"):
+		return s.dropIndex(p)
+	case p.accept("set", "search_path"):
+		return s.setSearchPath(p)
+	case p.accept("create", "index"), p.accept("create", "extension"), p.accept("create", "schema"),
+		p.accept("create", "sequence"), p.accept("alter", "sequence"), p.accept("alter", "schema"),
+		p.accept("drop", "sequence"), p.accept("drop", "schema"), p.accept("drop", "extension"),
+		p.accept("comment", "on"), p.accept("grant"), p.accept("revoke"), p.accept("set"),
+		p.accept("select", "pg_catalog"), p.accept("begin"), p.accept("commit"), p.accept("start", "transaction"):
+		// statements which don't change tables and types
+		return nil
+	}
+	return fmt.Errorf("statement is not supported")
+}
+
+// tables returns the built tables of schemas matching the filter
+func (s *ddlSchema) tables(filter SchemaFilter) []Table {
+	tables := make([]Table, 0, len(s.relations))
+	for _, t := range s.relations {
+		if !filter.Match(t.table.Schema) {
+			continue
+		}
+		table := t.table
+		table.Columns = make(map[string]Column, len(t.table.Columns))
+		for name, col := range t.table.Columns {
+			// pick up enum values and domain constraints added after the column
+			if dataType, err := s.resolveType(t.typeNames[name]); err == nil {
+				col.DataType = dataType
+			}
+			table.Columns[name] = col
+		}
+		table.PrimaryKeys = make(map[string]bool)
+		for _, colName := range t.primaryKey {
+			table.PrimaryKeys[colName] = true
+		}
+		table.Rules = make(map[string]func() string)
+		completeTable(&table)
+		tables = append(tables, table)
+	}
+	linkPartitions(tables)
+	return tables
+}
+
+func (s *ddlSchema) findTable(schema, name string) *ddlTable {
+	for _, t := range s.relations {
+		if t.table.Schema == schema && t.table.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// resolveType converts type as written in DDL to data type, user-defined types are looked up by name
+func (s *ddlSchema) resolveType(name string) (DataType, error) {
+	if elem, isArray := strings.CutSuffix(name, "[]"); isArray {
+		elemType, err := s.resolveType(elem)
+		if err != nil {
+			return nil, err
+		}
+		return Array{Elem: elemType}, nil
+	}
+	name = strings.TrimPrefix(name, "pg_catalog.")
+	qualified := name
+	if !strings.Contains(name, ".") {
+		qualified = QualifyName(s.defaultSchema, name)
+	}
+	if dataType, ok := s.types[qualified]; ok {
+		return dataType, nil
+	}
+	if name == "float" {
+		name = "float8"
+	}
+	return StringToDataType(name)
+}
+
+func (s *ddlSchema) createTable(p *ddlParser) error {
+	ifNotExists := p.accept("if", "not", "exists")
+	schema, name, err := p.name(s.defaultSchema)
+	if err != nil {
+		return err
+	}
+	if s.findTable(schema, name) != nil {
+		if ifNotExists {
+			return nil
+		}
+		return fmt.Errorf("table %s already exists", QualifyName(schema, name))
+	}
+	t := &ddlTable{
+		table: Table{
+			Schema:      schema,
+			Name:        name,
+			Kind:        KindTable,
+			Columns:     make(map[string]Column),
+			ForeignKeys: make([]ForeignKey, 0),
+			Uniques:     make([]UniqueConstraint, 0),
+			Checks:      make([]CheckConstraint, 0),
+		},
+		typeNames:  make(map[string]string),
+		primaryKey: make([]string, 0),
+	}
+
+	if p.accept("partition", "of") {
+		if err := s.partitionOf(p, t); err != nil {
+			return err
+		}
+	} else {
+		if p.accept("as") {
+			return fmt.Errorf("CREATE TABLE AS is not supported")
+		}
+		elements, err := p.group()
+		if err != nil {
+			return err
+		}
+		for _, element := range splitTokens(elements) {
+			q := p.sub(element)
+			if err := s.tableElement(q, t); err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.accept("partition", "by") {
+		method := strings.ToUpper(p.next().text)
+		key, err := p.group()
+		if err != nil {
+			return err
+		}
+		t.table.PartitionKey = fmt.Sprintf("%s (%s)", method, p.text(key))
+		if t.table.Kind == KindTable {
+			t.table.Kind = KindPartitionedTable
+		}
+	}
+	for !p.done() {
+		switch {
+		case p.accept("with"), p.accept("inherits"):
+			if _, err := p.group(); err != nil {
+				return err
+			}
+		case p.accept("tablespace"), p.accept("using"):
+			if _, err := p.ident(); err != nil {
+				return err
+			}
+		case p.accept("without", "oids"):
+		default:
+			return p.unexpected()
+		}
+	}
+	s.relations = append(s.relations, t)
+	return nil
+}
+
+// partitionOf reads PARTITION OF parent [(constraints)] FOR VALUES ... | DEFAULT, columns are taken from parent
+func (s *ddlSchema) partitionOf(p *ddlParser, t *ddlTable) error {
+	schema, name, err := p.name(s.defaultSchema)
+	if err != nil {
+		return err
+	}
+	parent := s.findTable(schema, name)
+	if parent == nil {
+		return fmt.Errorf("table %s not found", QualifyName(schema, name))
+	}
+	t.table.Kind = KindPartition
+	t.table.PartitionOf = parent.table.QualifiedName()
+	for colName, col := range parent.table.Columns {
+		t.table.Columns[colName] = col
+		t.typeNames[colName] = parent.typeNames[colName]
+	}
+	t.primaryKey = append(t.primaryKey, parent.primaryKey...)
+
+	if p.isSymbol("(") {
+		elements, err := p.group()
+		if err != nil {
+			return err
+		}
+		for _, element := range splitTokens(elements) {
+			if err := s.tableConstraint(p.sub(element), t); err != nil {
+				return err
+			}
+		}
+	}
+	switch {
+	case p.accept("default"):
+		t.table.PartitionBound = "DEFAULT"
+	case p.isWord("for", "values"):
+		bound := p.until(func() bool { return p.isWord("partition", "by") })
+		t.table.PartitionBound = whitespacePattern.ReplaceAllString(p.text(bound), " ")
+	default:
+		return fmt.Errorf("expected FOR VALUES or DEFAULT, got %s", p.describeNext())
+	}
+	return nil
+}
+
+// tableElement reads column definition or table constraint of CREATE TABLE
+func (s *ddlSchema) tableElement(p *ddlParser, t *ddlTable) error {
+	switch {
+	case p.isWord("like"):
+		return fmt.Errorf("LIKE in CREATE TABLE is not supported")
+	case p.isWord("constraint"), p.isWord("primary", "key"), p.isWord("unique"), p.isWord("foreign", "key"),
+		p.isWord("check"), p.isWord("exclude"):
+		return s.tableConstraint(p, t)
+	}
+	return s.columnDefinition(p, t)
+}
+
+// columnDefinition reads column name, type and column constraints and adds the column to the table
+func (s *ddlSchema) columnDefinition(p *ddlParser, t *ddlTable) error {
+	colName, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if _, exists := t.table.Columns[colName]; exists {
+		return fmt.Errorf("column %s specified more than once", colName)
+	}
+	typeName, err := p.typeName()
+	if err != nil {
+		return err
+	}
+	col := Column{Name: colName, IsNullable: true}
+	switch typeName {
+	case "serial", "serial4", "bigserial", "serial8", "smallserial", "serial2":
+		typeName = map[string]string{
+			"serial": "integer", "serial4": "integer", "bigserial": "bigint", "serial8": "bigint",
+			"smallserial": "smallint", "serial2": "smallint",
+		}[typeName]
+		col.IsNullable = false
+		col.Default = fmt.Sprintf("nextval('%s_%s_seq'::regclass)", t.table.Name, colName)
+	}
+	if col.DataType, err = s.resolveType(typeName); err != nil {
+		return err
+	}
+	t.table.Columns[colName] = col
+	t.typeNames[colName] = TypeName(col.DataType)
+
+	for !p.done() {
+		constraintName := ""
+		if p.accept("constraint") {
+			if constraintName, err = p.ident(); err != nil {
+				return err
+			}
+		}
+		col = t.table.Columns[colName]
+		switch {
+		case p.accept("not", "null"):
+			col.IsNullable = false
+		case p.accept("null"):
+			col.IsNullable = true
+		case p.accept("default"):
+			expr := p.until(func() bool {
+				tok := p.peek()
+				return tok.kind == tokenWord && columnConstraintWords[strings.ToLower(tok.text)]
+			})
+			if len(expr) == 0 {
+				return fmt.Errorf("DEFAULT without expression")
+			}
+			col.Default = p.text(expr)
+		case p.accept("collate"):
+			if _, _, err := p.name(""); err != nil {
+				return err
+			}
+		case p.accept("generated"):
+			if err := s.generated(p, &col); err != nil {
+				return err
+			}
+		case p.accept("primary", "key"):
+			if err := t.addPrimaryKey(constraintName, []string{colName}); err != nil {
+				return err
+			}
+			col = t.table.Columns[colName]
+		case p.accept("unique"):
+			p.accept("nulls", "not", "distinct")
+			p.accept("nulls", "distinct")
+			if err := t.addUnique(constraintName, []string{colName}, ""); err != nil {
+				return err
+			}
+		case p.accept("references"):
+			if err := s.addForeignKey(p, t, constraintName, []string{colName}); err != nil {
+				return err
+			}
+		case p.accept("check"):
+			cond, err := p.group()
+			if err != nil {
+				return err
+			}
+			p.accept("no", "inherit")
+			if constraintName == "" {
+				constraintName = t.constraintName(t.table.Name + "_" + colName + "_check")
+			}
+			t.table.Checks = append(t.table.Checks, CheckConstraint{
+				Name: constraintName, Definition: "CHECK (" + p.text(cond) + ")",
+			})
+		default:
+			return p.unexpected()
+		}
+		t.table.Columns[colName] = col
+	}
+	return nil
+}
+
+// generated reads identity or generated column after GENERATED
+func (s *ddlSchema) generated(p *ddlParser, col *Column) error {
+	generation := "ALWAYS"
+	if p.accept("by", "default") {
+		generation = "BY DEFAULT"
+	} else if err := p.expect("always"); err != nil {
+		return err
+	}
+	if err := p.expect("as"); err != nil {
+		return err
+	}
+	if p.accept("identity") {
+		if p.isSymbol("(") {
+			// sequence options
+			if _, err := p.group(); err != nil {
+				return err
+			}
+		}
+		col.IsIdentity = true
+		col.IdentityGeneration = generation
+		col.IsNullable = false
+		return nil
+	}
+	if _, err := p.group(); err != nil {
+		return err
+	}
+	if err := p.expect("stored"); err != nil {
+		return err
+	}
+	col.IsGenerated = true
+	return nil
+}
+
+// tableConstraint reads table constraint: [CONSTRAINT name] PRIMARY KEY | UNIQUE | FOREIGN KEY | CHECK
+func (s *ddlSchema) tableConstraint(p *ddlParser, t *ddlTable) error {
+	name := ""
+	if p.accept("constraint") {
+		var err error
+		if name, err = p.ident(); err != nil {
+			return err
+		}
+	}
+	switch {
+	case p.accept("primary", "key"):
+		cols, err := p.identList()
+		if err != nil {
+			return err
+		}
+		if err := t.addPrimaryKey(name, cols); err != nil {
+			return err
+		}
+		return indexTail(p)
+	case p.accept("unique"):
+		p.accept("nulls", "not", "distinct")
+		p.accept("nulls", "distinct")
+		cols, err := p.identList()
+		if err != nil {
+			return err
+		}
+		if err := t.addUnique(name, cols, ""); err != nil {
+			return err
+		}
+		return indexTail(p)
+	case p.accept("foreign", "key"):
+		cols, err := p.identList()
+		if err != nil {
+			return err
+		}
+		if err := p.expect("references"); err != nil {
+			return err
+		}
+		if err := s.addForeignKey(p, t, name, cols); err != nil {
+			return err
+		}
+	case p.accept("check"):
+		cond, err := p.group()
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			name = t.constraintName(t.table.Name + "_check")
+		}
+		t.table.Checks = append(t.table.Checks, CheckConstraint{Name: name, Definition: "CHECK (" + p.text(cond) + ")"})
+		p.accept("no", "inherit")
+		p.accept("not", "valid")
+	case p.accept("exclude"):
+		return fmt.Errorf("EXCLUDE constraints are not supported")
+	default:
+		return p.unexpected()
+	}
+	if !p.done() {
+		return p.unexpected()
+	}
+	return nil
+}
+
+// indexTail skips index parameters and constraint attributes of PRIMARY KEY and UNIQUE
+func indexTail(p *ddlParser) error {
+	for !p.done() {
+		switch {
+		case p.accept("include"), p.accept("with"):
+			if _, err := p.group(); err != nil {
+				return err
+			}
+		case p.accept("using", "index", "tablespace"):
+			if _, err := p.ident(); err != nil {
+				return err
+			}
+		case p.accept("deferrable"), p.accept("not", "deferrable"), p.accept("initially", "deferred"),
+			p.accept("initially", "immediate"), p.accept("not", "valid"):
+		default:
+			return p.unexpected()
+		}
+	}
+	return nil
+}
+
+// addForeignKey reads REFERENCES clause and adds foreign key on columns to the table.
+// The referenced table must exist, without column list its primary key is referenced.
+func (s *ddlSchema) addForeignKey(p *ddlParser, t *ddlTable, name string, cols []string) error {
+	refSchema, refName, err := p.name(s.defaultSchema)
+	if err != nil {
+		return err
+	}
+	ref := t
+	if refSchema != t.table.Schema || refName != t.table.Name {
+		ref = s.findTable(refSchema, refName)
+	}
+	if ref == nil {
+		return fmt.Errorf("referenced table %s not found", QualifyName(refSchema, refName))
+	}
+	var refCols []string
+	if p.isSymbol("(") {
+		if refCols, err = p.identList(); err != nil {
+			return err
+		}
+	} else {
+		refCols = ref.primaryKey
+	}
+	if len(refCols) != len(cols) {
+		return fmt.Errorf("foreign key of %s has %d columns, referenced %s has %d",
+			t.table.QualifiedName(), len(cols), ref.table.QualifiedName(), len(refCols))
+	}
+	for _, colName := range cols {
+		if _, ok := t.table.Columns[colName]; !ok {
+			return fmt.Errorf("column %s of %s not found", colName, t.table.QualifiedName())
+		}
+	}
+	for _, colName := range refCols {
+		if _, ok := ref.table.Columns[colName]; !ok {
+			return fmt.Errorf("column %s of %s not found", colName, ref.table.QualifiedName())
+		}
+	}
+
+	fk := ForeignKey{
+		Name:       name,
+		Columns:    cols,
+		RefSchema:  ref.table.Schema,
+		RefTable:   ref.table.Name,
+		RefColumns: refCols,
+	}
+	if fk.Name == "" {
+		fk.Name = t.constraintName(t.table.Name + "_" + strings.Join(cols, "_") + "_fkey")
+	}
+	for !p.done() {
+		switch {
+		case p.accept("match"):
+			p.next()
+		case p.accept("on", "delete"), p.accept("on", "update"):
+			switch {
+			case p.accept("set", "null"), p.accept("set", "default"):
+				if p.isSymbol("(") {
+					if _, err := p.group(); err != nil {
+						return err
+					}
+				}
+			case p.accept("cascade"), p.accept("restrict"), p.accept("no", "action"):
+			default:
+				return p.unexpected()
+			}
+		case p.accept("deferrable"):
+			fk.Deferrable = true
+		case p.accept("not", "deferrable"), p.accept("initially", "deferred"), p.accept("initially", "immediate"),
+			p.accept("not", "valid"):
+		default:
+			// the rest belongs to the column definition
+			t.table.ForeignKeys = append(t.table.ForeignKeys, fk)
+			return nil
+		}
+	}
+	t.table.ForeignKeys = append(t.table.ForeignKeys, fk)
+	return nil
+}
+
+// addPrimaryKey sets primary key of the table, its columns become NOT NULL
+func (t *ddlTable) addPrimaryKey(name string, cols []string) error {
+	if len(t.primaryKey) > 0 && t.table.Kind != KindPartition {
+		return fmt.Errorf("multiple primary keys for table %s are not allowed", t.table.QualifiedName())
+	}
+	for _, colName := range cols {
+		col, ok := t.table.Columns[colName]
+		if !ok {
+			return fmt.Errorf("column %s of %s not found", colName, t.table.QualifiedName())
+		}
+		col.IsNullable = false
+		t.table.Columns[colName] = col
+	}
+	if name == "" {
+		name = t.constraintName(t.table.Name + "_pkey")
+	}
+	t.primaryKey = cols
+	t.pkName = name
+	t.table.Uniques = append(t.table.Uniques, UniqueConstraint{Name: name, Columns: cols})
+	return nil
+}
+
+func (t *ddlTable) addUnique(name string, cols []string, predicate string) error {
+	for _, colName := range cols {
+		if _, ok := t.table.Columns[colName]; !ok {
+			return fmt.Errorf("column %s of %s not found", colName, t.table.QualifiedName())
+		}
+	}
+	if name == "" {
+		name = t.constraintName(t.table.Name + "_" + strings.Join(cols, "_") + "_key")
+	}
+	t.table.Uniques = append(t.table.Uniques, UniqueConstraint{Name: name, Columns: cols, Predicate: predicate})
+	return nil
+}
+
+// constraintName returns base, or base followed by a number if the table already has such constraint
+func (t *ddlTable) constraintName(base string) string {
+	taken := make(map[string]bool)
+	for _, fk := range t.table.ForeignKeys {
+		taken[fk.Name] = true
+	}
+	for _, u := range t.table.Uniques {
+		taken[u.Name] = true
+	}
+	for _, c := range t.table.Checks {
+		taken[c.Name] = true
+	}
+	name := base
+	for i := 1; taken[name]; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	return name
+}
+
+// dropConstraint removes constraint by name, reports whether it was found
+func (t *ddlTable) dropConstraint(name string) bool {
+	found := false
+	fks := make([]ForeignKey, 0, len(t.table.ForeignKeys))
+	for _, fk := range t.table.ForeignKeys {
+		if fk.Name == name {
+			found = true
+			continue
+		}
+		fks = append(fks, fk)
+	}
+	uniques := make([]UniqueConstraint, 0, len(t.table.Uniques))
+	for _, u := range t.table.Uniques {
+		if u.Name == name {
+			found = true
+			continue
+		}
+		uniques = append(uniques, u)
+	}
+	checks := make([]CheckConstraint, 0, len(t.table.Checks))
+	for _, c := range t.table.Checks {
+		if c.Name == name {
+			found = true
+			continue
+		}
+		checks = append(checks, c)
+	}
+	if t.pkName == name {
+		t.primaryKey = make([]string, 0)
+		t.pkName = ""
+	}
+	t.table.ForeignKeys, t.table.Uniques, t.table.Checks = fks, uniques, checks
+	return found
+}
+
+// dropColumn removes column and the keys it belongs to
+func (t *ddlTable) dropColumn(colName string) {
+	delete(t.table.Columns, colName)
+	delete(t.typeNames, colName)
+	for _, fk := range t.table.ForeignKeys {
+		if containsString(fk.Columns, colName) {
+			t.dropConstraint(fk.Name)
+		}
+	}
+	for _, u := range t.table.Uniques {
+		if containsString(u.Columns, colName) {
+			t.dropConstraint(u.Name)
+		}
+	}
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ddlSchema) alterTable(p *ddlParser) error {
+	ifExists := p.accept("if", "exists")
+	p.accept("only")
+	schema, name, err := p.name(s.defaultSchema)
+	if err != nil {
+		return err
+	}
+	t := s.findTable(schema, name)
+	if t == nil {
+		if ifExists {
+			return nil
+		}
+		return fmt.Errorf("table %s not found", QualifyName(schema, name))
+	}
+	for _, action := range splitTokens(p.rest()) {
+		if err := s.alterTableAction(p.sub(action), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ddlSchema) alterTableAction(p *ddlParser, t *ddlTable) error {
+	switch {
+	case p.isWord("add", "constraint"), p.isWord("add", "primary"), p.isWord("add", "unique"),
+		p.isWord("add", "foreign"), p.isWord("add", "check"), p.isWord("add", "exclude"):
+		p.next()
+		return s.tableConstraint(p, t)
+	case p.accept("add"):
+		p.accept("column")
+		if p.accept("if", "not", "exists") {
+			if _, exists := t.table.Columns[unquoteIdent(p.peek().text)]; exists {
+				return nil
+			}
+		}
+		return s.columnDefinition(p, t)
+	case p.accept("drop", "constraint"):
+		ifExists := p.accept("if", "exists")
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if !t.dropConstraint(name) && !ifExists {
+			return fmt.Errorf("constraint %s of %s not found", name, t.table.QualifiedName())
+		}
+	case p.accept("drop"):
+		p.accept("column")
+		ifExists := p.accept("if", "exists")
+		colName, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if _, ok := t.table.Columns[colName]; !ok {
+			if ifExists {
+				return nil
+			}
+			return fmt.Errorf("column %s of %s not found", colName, t.table.QualifiedName())
+		}
+		t.dropColumn(colName)
+	case p.accept("alter"):
+		return s.alterColumn(p, t)
+	case p.accept("owner", "to"), p.accept("replica", "identity"), p.accept("cluster", "on"),
+		p.accept("enable"), p.accept("disable"), p.accept("force"), p.accept("no", "force"):
+		// don't change columns and constraints
+		p.rest()
+	default:
+		return fmt.Errorf("ALTER TABLE %s is not supported", strings.ToUpper(p.describeNext()))
+	}
+	p.accept("cascade")
+	p.accept("restrict")
+	if !p.done() {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (s *ddlSchema) alterColumn(p *ddlParser, t *ddlTable) error {
+	p.accept("column")
+	colName, err := p.ident()
+	if err != nil {
+		return err
+	}
+	col, ok := t.table.Columns[colName]
+	if !ok {
+		return fmt.Errorf("column %s of %s not found", colName, t.table.QualifiedName())
+	}
+	switch {
+	case p.accept("set", "not", "null"):
+		col.IsNullable = false
+	case p.accept("drop", "not", "null"):
+		col.IsNullable = true
+	case p.accept("set", "default"):
+		col.Default = p.text(p.rest())
+	case p.accept("drop", "default"):
+		col.Default = ""
+	case p.accept("set", "data", "type"), p.accept("type"):
+		typeName, err := p.typeName()
+		if err != nil {
+			return err
+		}
+		if col.DataType, err = s.resolveType(typeName); err != nil {
+			return err
+		}
+		t.typeNames[colName] = TypeName(col.DataType)
+		if p.accept("collate") {
+			if _, _, err := p.name(""); err != nil {
+				return err
+			}
+		}
+		if p.accept("using") {
+			p.rest()
+		}
+	case p.accept("add", "generated"):
+		if err := s.generated(p, &col); err != nil {
+			return err
+		}
+	case p.accept("drop", "identity"):
+		p.accept("if", "exists")
+		col.IsIdentity = false
+		col.IdentityGeneration = ""
+	case p.accept("drop", "expression"):
+		p.accept("if", "exists")
+		col.IsGenerated = false
+	case p.accept("set", "statistics"), p.accept("set", "storage"), p.accept("set", "compression"),
+		p.accept("set"), p.accept("reset"), p.accept("restart"):
+		// don't change generated values
+		p.rest()
+	default:
+		return fmt.Errorf("ALTER COLUMN %s is not supported", strings.ToUpper(p.describeNext()))
+	}
+	t.table.Columns[colName] = col
+	return nil
+}
+
+// createType reads CREATE TYPE name AS ENUM (...) or composite CREATE TYPE name AS (...)
+func (s *ddlSchema) createType(p *ddlParser) error {
+	schema, name, err := p.name(s.defaultSchema)
+	if err != nil {
+		return err
+	}
+	qualified := QualifyName(schema, name)
+	if err := p.expect("as"); err != nil {
+		return err
+	}
+	switch {
+	case p.accept("enum"):
+		items, err := p.group()
+		if err != nil {
+			return err
+		}
+		labels := make([]string, 0)
+		for _, item := range splitTokens(items) {
+			if len(item) != 1 || item[0].kind != tokenString {
+				return fmt.Errorf("enum value %s is not a string literal", p.text(item))
+			}
+			labels = append(labels, unquoteLiteral(item[0].text))
+		}
+		s.types[qualified] = Enum{Name: qualified, Labels: labels}
+	case p.isSymbol("("):
+		items, err := p.group()
+		if err != nil {
+			return err
+		}
+		composite := Composite{Name: qualified, Attributes: make([]CompositeAttribute, 0)}
+		for _, item := range splitTokens(items) {
+			q := p.sub(item)
+			attrName, err := q.ident()
+			if err != nil {
+				return err
+			}
+			typeName, err := q.typeName()
+			if err != nil {
+				return err
+			}
+			dataType, err := s.resolveType(typeName)
+			if err != nil {
+				return fmt.Errorf("attribute %s: %v", attrName, err)
+			}
+			composite.Attributes = append(composite.Attributes, CompositeAttribute{Name: attrName, DataType: dataType})
+		}
+		s.types[qualified] = composite
+	default:
+		return fmt.Errorf("CREATE TYPE %s AS %s is not supported", qualified, strings.ToUpper(p.describeNext()))
+	}
+	if !p.done() {
+		return p.unexpected()
+	}
+	return nil
+}
+
+// alterType reads ALTER TYPE name ADD VALUE or RENAME VALUE of enum
+func (s *ddlSchema) alterType(p *ddlParser) error {
+	schema, name, err := p.name(s.defaultSchema)
+	if err != nil {
+		return err
+	}
+	qualified := QualifyName(schema, name)
+	enum, ok := s.types[qualified].(Enum)
+	if !ok {
+		return fmt.Errorf("enum %s not found", qualified)
+	}
+	labels := append([]string{}, enum.Labels...)
+	literal := func() (string, error) {
+		tok := p.next()
+		if tok.kind != tokenString {
+			return "", fmt.Errorf("expected string literal, got %s", tok.text)
+		}
+		return unquoteLiteral(tok.text), nil
+	}
+	switch {
+	case p.accept("add", "value"):
+		ifNotExists := p.accept("if", "not", "exists")
+		label, err := literal()
+		if err != nil {
+			return err
+		}
+		if enum.HasLabel(label) {
+			if ifNotExists {
+				return nil
+			}
+			return fmt.Errorf("enum %s already has value %s", qualified, label)
+		}
+		position := len(labels)
+		before := p.accept("before")
+		if before || p.accept("after") {
+			neighbour, err := literal()
+			if err != nil {
+				return err
+			}
+			position = -1
+			for i, l := range labels {
+				if l == neighbour {
+					position = i
+					if !before {
+						position++
+					}
+				}
+			}
+			if position < 0 {
+				return fmt.Errorf("enum %s has no value %s", qualified, neighbour)
+			}
+		}
+		labels = append(labels[:position], append([]string{label}, labels[position:]...)...)
+	case p.accept("rename", "value"):
+		from, err := literal()
+		if err != nil {
+			return err
+		}
+		if err := p.expect("to"); err != nil {
+			return err
+		}
+		to, err := literal()
+		if err != nil {
+			return err
+		}
+		for i, l := range labels {
+			if l == from {
+				labels[i] = to
+			}
+		}
+	default:
+		return fmt.Errorf("ALTER TYPE %s is not supported", strings.ToUpper(p.describeNext()))
+	}
+	if !p.done() {
+		return p.unexpected()
+	}
+	enum.Labels = labels
+	s.types[qualified] = enum
+	return nil
+}
+
+// createDomain reads CREATE DOMAIN name [AS] type [DEFAULT ...] [CONSTRAINT name] [NOT NULL | NULL | CHECK (...)]
+func (s *ddlSchema) createDomain(p *ddlParser) error {
+	schema, name, err := p.name(s.defaultSchema)
+	if err != nil {
+		return err
+	}
+	qualified := QualifyName(schema, name)
+	p.accept("as")
+	typeName, err := p.typeName()
+	if err != nil {
+		return err
+	}
+	base, err := s.resolveType(typeName)
+	if err != nil {
+		return err
+	}
+	checks := make([]CheckConstraint, 0)
+	for !p.done() {
+		constraintName := ""
+		if p.accept("constraint") {
+			if constraintName, err = p.ident(); err != nil {
+				return err
+			}
+		}
+		switch {
+		case p.accept("not", "null"), p.accept("null"):
+		case p.accept("collate"):
+			if _, _, err := p.name(""); err != nil {
+				return err
+			}
+		case p.accept("default"):
+			p.until(func() bool {
+				tok := p.peek()
+				return tok.kind == tokenWord && columnConstraintWords[strings.ToLower(tok.text)]
+			})
+		case p.accept("check"):
+			cond, err := p.group()
+			if err != nil {
+				return err
+			}
+			if constraintName == "" {
+				constraintName = domainCheckName(name, checks)
+			}
+			checks = append(checks, CheckConstraint{Name: constraintName, Definition: "CHECK (" + p.text(cond) + ")"})
+		default:
+			return p.unexpected()
+		}
+	}
+	s.types[qualified] = NewDomain(qualified, base, checks)
+	return nil
+}
+
+// alterDomain reads ALTER DOMAIN name ADD [CONSTRAINT name] CHECK (...) or DROP CONSTRAINT name
+func (s *ddlSchema) alterDomain(p *ddlParser) error {
+	schema, name, err := p.name(s.defaultSchema)
+	if err != nil {
+		return err
+	}
+	qualified := QualifyName(schema, name)
+	domain, ok := s.types[qualified].(Domain)
+	if !ok {
+		return fmt.Errorf("domain %s not found", qualified)
+	}
+	checks := append([]CheckConstraint{}, domain.Checks...)
+	switch {
+	case p.accept("add"):
+		constraintName := ""
+		if p.accept("constraint") {
+			if constraintName, err = p.ident(); err != nil {
+				return err
+			}
+		}
+		if err := p.expect("check"); err != nil {
+			return err
+		}
+		cond, err := p.group()
+		if err != nil {
+			return err
+		}
+		p.accept("not", "valid")
+		if constraintName == "" {
+			constraintName = domainCheckName(name, checks)
+		}
+		checks = append(checks, CheckConstraint{Name: constraintName, Definition: "CHECK (" + p.text(cond) + ")"})
+	case p.accept("drop", "constraint"):
+		ifExists := p.accept("if", "exists")
+		constraintName, err := p.ident()
+		if err != nil {
+			return err
+		}
+		kept := make([]CheckConstraint, 0, len(checks))
+		for _, c := range checks {
+			if c.Name != constraintName {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) == len(checks) && !ifExists {
+			return fmt.Errorf("constraint %s of domain %s not found", constraintName, qualified)
+		}
+		checks = kept
+		p.accept("cascade")
+		p.accept("restrict")
+	case p.accept("set", "not", "null"), p.accept("drop", "not", "null"), p.accept("owner", "to"),
+		p.accept("set", "default"), p.accept("drop", "default"), p.accept("validate", "constraint"):
+		p.rest()
+	default:
+		return fmt.Errorf("ALTER DOMAIN %s is not supported", strings.ToUpper(p.describeNext()))
+	}
+	if !p.done() {
+		return p.unexpected()
+	}
+	s.types[qualified] = NewDomain(qualified, domain.Base, checks)
+	return nil
+}
+
+// domainCheckName returns default name of a domain CHECK constraint
+func domainCheckName(domain string, checks []CheckConstraint) string {
+	name := domain + "_check"
+	for i := 1; ; i++ {
+		taken := false
+		for _, c := range checks {
+			taken = taken || c.Name == name
+		}
+		if !taken {
+			return name
+		}
+		name = fmt.Sprintf("%s_check%d", domain, i)
+	}
+}
+
+// createUniqueIndex reads CREATE UNIQUE INDEX on plain columns, the index is a unique constraint of the table
+func (s *ddlSchema) createUniqueIndex(p *ddlParser) error {
+	p.accept("concurrently")
+	ifNotExists := p.accept("if", "not", "exists")
+	name := ""
+	if !p.isWord("on") {
+		var err error
+		if name, err = p.ident(); err != nil {
+			return err
+		}
+	}
+	if err := p.expect("on"); err != nil {
+		return err
+	}
+	p.accept("only")
+	schema, tableName, err := p.name(s.defaultSchema)
+	if err != nil {
+		return err
+	}
+	t := s.findTable(schema, tableName)
+	if t == nil {
+		return fmt.Errorf("table %s not found", QualifyName(schema, tableName))
+	}
+	if p.accept("using") {
+		p.next()
+	}
+	items, err := p.group()
+	if err != nil {
+		return err
+	}
+	cols := make([]string, 0)
+	for _, item := range splitTokens(items) {
+		q := p.sub(item)
+		colName, err := q.ident()
+		if err != nil || !(q.done() || q.accept("asc") || q.accept("desc")) {
+			return fmt.Errorf("unique index on expression %s is not supported", p.text(item))
+		}
+		cols = append(cols, colName)
+	}
+	predicate := ""
+	for !p.done() {
+		switch {
+		case p.accept("include"), p.accept("with"):
+			if _, err := p.group(); err != nil {
+				return err
+			}
+		case p.accept("nulls", "not", "distinct"), p.accept("nulls", "distinct"):
+		case p.accept("tablespace"):
+			p.next()
+		case p.accept("where"):
+			predicate = p.text(p.rest())
+		default:
+			return p.unexpected()
+		}
+	}
+	if name != "" {
+		for _, u := range t.table.Uniques {
+			if u.Name == name {
+				if ifNotExists {
+					return nil
+				}
+				return fmt.Errorf("index %s already exists", name)
+			}
+		}
+	}
+	if name == "" {
+		name = t.constraintName(t.table.Name + "_" + strings.Join(cols, "_") + "_idx")
+	}
+	return t.addUnique(name, cols, predicate)
+}
+
+func (s *ddlSchema) dropTables(p *ddlParser) error {
+	ifExists := p.accept("if", "exists")
+	for _, item := range splitTokens(p.rest()) {
+		q := p.sub(item)
+		schema, name, err := q.name(s.defaultSchema)
+		if err != nil {
+			return err
+		}
+		found := false
+		for i, t := range s.relations {
+			if t.table.Schema == schema && t.table.Name == name {
+				s.relations = append(s.relations[:i], s.relations[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found && !ifExists {
+			return fmt.Errorf("table %s not found", QualifyName(schema, name))
+		}
+	}
+	return nil
+}
+
+func (s *ddlSchema) dropTypes(p *ddlParser) error {
+	ifExists := p.accept("if", "exists")
+	for _, item := range splitTokens(p.rest()) {
+		q := p.sub(item)
+		schema, name, err := q.name(s.defaultSchema)
+		if err != nil {
+			return err
+		}
+		qualified := QualifyName(schema, name)
+		if _, ok := s.types[qualified]; !ok && !ifExists {
+			return fmt.Errorf("type %s not found", qualified)
+		}
+		delete(s.types, qualified)
+	}
+	return nil
+}
+
+// dropIndex removes unique constraints created by CREATE UNIQUE INDEX, other indexes are ignored
+func (s *ddlSchema) dropIndex(p *ddlParser) error {
+	p.accept("concurrently")
+	p.accept("if", "exists")
+	for _, item := range splitTokens(p.rest()) {
+		schema, name, err := p.sub(item).name(s.defaultSchema)
+		if err != nil {
+			return err
+		}
+		for _, t := range s.relations {
+			if t.table.Schema == schema {
+				t.dropConstraint(name)
+			}
+		}
+	}
+	return nil
+}
+
+// setSearchPath makes the first schema of search_path the schema of unqualified names
+func (s *ddlSchema) setSearchPath(p *ddlParser) error {
+	if !p.acceptSymbol("=") {
+		if err := p.expect("to"); err != nil {
+			return err
+		}
+	}
+	tok := p.next()
+	switch tok.kind {
+	case tokenWord, tokenQuoted:
+		s.defaultSchema = unquoteIdent(tok.text)
+	case tokenString:
+		s.defaultSchema = strings.TrimSpace(strings.Split(unquoteLiteral(tok.text), ",")[0])
+	}
+	if s.defaultSchema == "" || strings.EqualFold(s.defaultSchema, "default") {
+		s.defaultSchema = defaultDDLSchema
+	}
+	return nil
+}
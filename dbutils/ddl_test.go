@@ -0,0 +1,88 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDDL_Migrations(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"0001_init.up.sql": `
+CREATE TYPE order_status AS ENUM ('new', 'paid');
+CREATE DOMAIN amount AS numeric(10, 2) CHECK (VALUE > 0);
+CREATE TABLE users (
+    id    serial PRIMARY KEY,
+    email varchar(100) NOT NULL UNIQUE
+);`,
+		"0001_init.down.sql": `DROP TABLE users;`,
+		"0002_orders.sql": `-- +goose Up
+CREATE TABLE shop.orders (
+    id      bigint GENERATED ALWAYS AS IDENTITY,
+    user_id int NOT NULL,
+    status  order_status NOT NULL DEFAULT 'new',
+    total   amount,
+    "Note"  text,
+    CONSTRAINT orders_pkey PRIMARY KEY (id)
+);
+ALTER TABLE ONLY shop.orders ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES users (id);
+-- +goose StatementBegin
+CREATE FUNCTION touch() RETURNS trigger AS $$ BEGIN RETURN NEW; END; $$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+-- +goose Down
+DROP TABLE shop.orders;`,
+		"0010_status.up.sql": `ALTER TYPE order_status ADD VALUE 'shipped';`,
+	}
+	for name, src := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+
+	tables, unparsed, err := ParseDDL(dir, SchemaFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, unparsed, 1)
+	assert.Equal(t, filepath.Join(dir, "0002_orders.sql"), unparsed[0].File)
+	assert.Equal(t, 12, unparsed[0].Line)
+	assert.Len(t, tables, 2)
+
+	users, orders := tables[0], tables[1]
+	assert.Equal(t, "public.users", users.QualifiedName())
+	assert.Equal(t, "nextval('users_id_seq'::regclass)", users.Columns["id"].Default)
+	assert.Equal(t, []UniqueConstraint{{Name: "users_pkey", Columns: []string{"id"}},
+		{Name: "users_email_key", Columns: []string{"email"}}}, users.Uniques)
+
+	assert.Equal(t, "shop.orders", orders.QualifiedName())
+	assert.Equal(t, map[string]bool{"id": true}, orders.PrimaryKeys)
+	assert.Equal(t, "ALWAYS", orders.Columns["id"].IdentityGeneration)
+	assert.Equal(t, []string{"public.users"}, orders.DependsOn)
+	assert.True(t, orders.Columns["user_id"].IsForeignKey)
+	assert.Equal(t, "'new'", orders.Columns["status"].Default)
+	assert.Equal(t, []string{"new", "paid", "shipped"}, orders.Columns["status"].DataType.(Enum).Labels)
+	assert.True(t, orders.Columns["total"].Check.Min.IsPresent())
+	assert.Contains(t, orders.Columns, "Note")
+}
+
+func TestParseDDL_Unparsed(t *testing.T) {
+	schema := newDDLSchema()
+	schema.apply("schema.sql", `
+CREATE TABLE a (id int PRIMARY KEY, kind widget);
+CREATE TABLE b (id int PRIMARY KEY, a_id int REFERENCES missing (id));
+CREATE TABLE c (id int, note text, CONSTRAINT c_note CHECK (note <> ''));
+ALTER TABLE c RENAME COLUMN note TO body;
+CREATE UNIQUE INDEX c_lower_note ON c (lower(note));
+CREATE INDEX c_note_idx ON c (note);
+CREATE VIEW v AS SELECT 1;`)
+
+	lines := make([]int, 0)
+	for _, u := range schema.unparsed {
+		lines = append(lines, u.Line)
+	}
+	assert.Equal(t, []int{2, 3, 5, 6, 8}, lines)
+	assert.Contains(t, schema.unparsed[0].Reason, "unknown data type: widget")
+	assert.Contains(t, schema.unparsed[1].Reason, "referenced table public.missing not found")
+
+	tables := schema.tables(SchemaFilter{})
+	assert.Len(t, tables, 1)
+	assert.Equal(t, []CheckConstraint{{Name: "c_note", Definition: "CHECK (note <> '')"}}, tables[0].Checks)
+}
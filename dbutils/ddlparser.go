@@ -0,0 +1,473 @@
+package dbutils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ddlStatement - one SQL statement of a DDL file
+type ddlStatement struct {
+	file string
+	line int // line where the statement starts
+	text string
+}
+
+var dollarTagPattern = regexp.MustCompile(`^\$([A-Za-z_]\w*)?\$`)
+
+// splitStatements splits SQL source into statements by semicolons outside of string literals,
+// quoted identifiers and dollar quoted bodies. Comments are dropped.
+func splitStatements(file, src string) []ddlStatement {
+	statements := make([]ddlStatement, 0)
+	var b strings.Builder
+	line, startLine := 1, 0
+	write := func(s string) {
+		if startLine == 0 && strings.TrimSpace(s) != "" {
+			startLine = line + strings.Count(s[:len(s)-len(strings.TrimLeft(s, " \t\r\n"))], "\n")
+		}
+		line += strings.Count(s, "\n")
+		b.WriteString(s)
+	}
+	skip := func(s string) {
+		line += strings.Count(s, "\n")
+		b.WriteByte(' ')
+	}
+	flush := func() {
+		if text := strings.TrimSpace(b.String()); text != "" {
+			statements = append(statements, ddlStatement{file: file, line: startLine, text: text})
+		}
+		b.Reset()
+		startLine = 0
+	}
+
+	for i := 0; i < len(src); {
+		rest := src[i:]
+		n := 1
+		switch {
+		case strings.HasPrefix(rest, "--"):
+			if n = strings.IndexByte(rest, '\n'); n < 0 {
+				n = len(rest)
+			}
+			skip(rest[:n])
+		case strings.HasPrefix(rest, "/*"):
+			if n = strings.Index(rest[2:], "*/"); n < 0 {
+				n = len(rest)
+			} else {
+				n += 4
+			}
+			skip(rest[:n])
+		case rest[0] == '\'' || rest[0] == '"':
+			n = quotedLength(rest)
+			write(rest[:n])
+		case rest[0] == '$' && dollarTagPattern.MatchString(rest):
+			tag := dollarTagPattern.FindString(rest)
+			if end := strings.Index(rest[len(tag):], tag); end < 0 {
+				n = len(rest)
+			} else {
+				n = len(tag) + end + len(tag)
+			}
+			write(rest[:n])
+		case rest[0] == ';':
+			flush()
+		default:
+			write(rest[:1])
+		}
+		i += n
+	}
+	flush()
+	return statements
+}
+
+// quotedLength returns length of the string literal or quoted identifier s starts with, doubled quotes are escapes
+func quotedLength(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		if s[i] != quote {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(s)
+}
+
+// gooseUpSections blanks lines outside of "-- +goose Up" sections, line numbers are kept.
+// Sources without goose annotations are returned as is.
+func gooseUpSections(src string) string {
+	lines := strings.Split(src, "\n")
+	annotated := false
+	for _, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(l), "-- +goose Up") {
+			annotated = true
+			break
+		}
+	}
+	if !annotated {
+		return src
+	}
+	up := false
+	for i, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		switch {
+		case strings.HasPrefix(trimmed, "-- +goose Up"):
+			up = true
+		case strings.HasPrefix(trimmed, "-- +goose Down"):
+			up = false
+		}
+		if !up {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// kinds of DDL tokens
+const (
+	tokenWord   = iota // keyword or identifier
+	tokenQuoted        // quoted identifier
+	tokenString        // string literal or dollar quoted string
+	tokenNumber
+	tokenSymbol
+)
+
+type ddlToken struct {
+	kind       int
+	text       string
+	start, end int // offsets in the statement text
+}
+
+// ddlParser - cursor over tokens of a statement
+type ddlParser struct {
+	src    string
+	tokens []ddlToken
+	pos    int
+}
+
+func newDDLParser(src string) *ddlParser {
+	tokens := make([]ddlToken, 0)
+	for i := 0; i < len(src); {
+		c := src[i]
+		start := i
+		kind := tokenSymbol
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+			continue
+		case c == '\'' || c == '"':
+			i += quotedLength(src[i:])
+			kind = tokenString
+			if c == '"' {
+				kind = tokenQuoted
+			}
+		case c == '$' && dollarTagPattern.MatchString(src[i:]):
+			tag := dollarTagPattern.FindString(src[i:])
+			if end := strings.Index(src[i+len(tag):], tag); end < 0 {
+				i = len(src)
+			} else {
+				i += len(tag) + end + len(tag)
+			}
+			kind = tokenString
+		case isWordStart(c):
+			for i < len(src) && (isWordStart(src[i]) || isDigit(src[i]) || src[i] == '$') {
+				i++
+			}
+			kind = tokenWord
+			// E'...' escape string literal
+			if i-start == 1 && (c == 'E' || c == 'e') && i < len(src) && src[i] == '\'' {
+				i += quotedLength(src[i:])
+				kind = tokenString
+			}
+		case isDigit(c):
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			kind = tokenNumber
+		default:
+			i++
+		}
+		tokens = append(tokens, ddlToken{kind: kind, text: src[start:i], start: start, end: i})
+	}
+	return &ddlParser{src: src, tokens: tokens}
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// sub returns parser over part of the tokens
+func (p *ddlParser) sub(tokens []ddlToken) *ddlParser {
+	return &ddlParser{src: p.src, tokens: tokens}
+}
+
+func (p *ddlParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+// peek returns the next token, empty token at the end
+func (p *ddlParser) peek() ddlToken {
+	if p.done() {
+		return ddlToken{kind: tokenSymbol}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *ddlParser) next() ddlToken {
+	tok := p.peek()
+	if !p.done() {
+		p.pos++
+	}
+	return tok
+}
+
+// isWord reports whether the next tokens are the given keywords
+func (p *ddlParser) isWord(words ...string) bool {
+	if p.pos+len(words) > len(p.tokens) {
+		return false
+	}
+	for i, word := range words {
+		tok := p.tokens[p.pos+i]
+		if tok.kind != tokenWord || !strings.EqualFold(tok.text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// accept consumes the keywords if they are next
+func (p *ddlParser) accept(words ...string) bool {
+	if !p.isWord(words...) {
+		return false
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *ddlParser) isSymbol(symbol string) bool {
+	tok := p.peek()
+	return tok.kind == tokenSymbol && tok.text == symbol
+}
+
+func (p *ddlParser) acceptSymbol(symbol string) bool {
+	if !p.isSymbol(symbol) {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+// unexpected returns error about the next token
+func (p *ddlParser) unexpected() error {
+	if p.done() {
+		return fmt.Errorf("unexpected end of statement")
+	}
+	return fmt.Errorf("unexpected %s", p.peek().text)
+}
+
+func (p *ddlParser) expect(words ...string) error {
+	if !p.accept(words...) {
+		return fmt.Errorf("expected %s, got %s", strings.ToUpper(strings.Join(words, " ")), p.describeNext())
+	}
+	return nil
+}
+
+func (p *ddlParser) describeNext() string {
+	if p.done() {
+		return "end of statement"
+	}
+	return p.peek().text
+}
+
+// ident consumes identifier, unquoted identifiers are folded to lower case
+func (p *ddlParser) ident() (string, error) {
+	tok := p.peek()
+	if tok.kind != tokenWord && tok.kind != tokenQuoted {
+		return "", fmt.Errorf("expected identifier, got %s", p.describeNext())
+	}
+	p.pos++
+	return unquoteIdent(tok.text), nil
+}
+
+// name consumes [schema.]name, defaultSchema is used for unqualified names
+func (p *ddlParser) name(defaultSchema string) (string, string, error) {
+	name, err := p.ident()
+	if err != nil {
+		return "", "", err
+	}
+	if !p.acceptSymbol(".") {
+		return defaultSchema, name, nil
+	}
+	schema := name
+	if name, err = p.ident(); err != nil {
+		return "", "", err
+	}
+	return schema, name, nil
+}
+
+// group consumes parenthesized tokens and returns tokens inside the parentheses
+func (p *ddlParser) group() ([]ddlToken, error) {
+	if !p.isSymbol("(") {
+		return nil, fmt.Errorf("expected (, got %s", p.describeNext())
+	}
+	depth := 0
+	for i := p.pos; i < len(p.tokens); i++ {
+		switch {
+		case p.tokens[i].kind != tokenSymbol:
+		case p.tokens[i].text == "(":
+			depth++
+		case p.tokens[i].text == ")":
+			depth--
+			if depth == 0 {
+				inner := p.tokens[p.pos+1 : i]
+				p.pos = i + 1
+				return inner, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unbalanced parentheses")
+}
+
+// identList consumes parenthesized list of identifiers
+func (p *ddlParser) identList() ([]string, error) {
+	inner, err := p.group()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for _, item := range splitTokens(inner) {
+		q := p.sub(item)
+		name, err := q.ident()
+		if err != nil {
+			return nil, err
+		}
+		if !q.done() {
+			return nil, fmt.Errorf("expected column name, got %s", p.text(item))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// until consumes tokens up to the end or the first token at parentheses depth 0 for which stop is true
+func (p *ddlParser) until(stop func() bool) []ddlToken {
+	start := p.pos
+	depth := 0
+	for !p.done() {
+		if depth == 0 && stop() {
+			break
+		}
+		switch {
+		case p.isSymbol("(") || p.isSymbol("["):
+			depth++
+		case p.isSymbol(")") || p.isSymbol("]"):
+			depth--
+		}
+		p.pos++
+	}
+	return p.tokens[start:p.pos]
+}
+
+// rest consumes the remaining tokens
+func (p *ddlParser) rest() []ddlToken {
+	tokens := p.tokens[p.pos:]
+	p.pos = len(p.tokens)
+	return tokens
+}
+
+// text returns source text of the tokens
+func (p *ddlParser) text(tokens []ddlToken) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	return p.src[tokens[0].start:tokens[len(tokens)-1].end]
+}
+
+// splitTokens splits tokens by commas outside of parentheses and brackets
+func splitTokens(tokens []ddlToken) [][]ddlToken {
+	parts := make([][]ddlToken, 0)
+	depth := 0
+	start := 0
+	for i, tok := range tokens {
+		if tok.kind != tokenSymbol {
+			continue
+		}
+		switch tok.text {
+		case "(", "[":
+			depth++
+		case ")", "]":
+			depth--
+		case ",":
+			if depth == 0 {
+				parts = append(parts, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(tokens) {
+		parts = append(parts, tokens[start:])
+	}
+	return parts
+}
+
+// words which end a column type or a DEFAULT expression in column definition
+var columnConstraintWords = map[string]bool{
+	"constraint": true, "not": true, "null": true, "default": true, "primary": true, "unique": true,
+	"references": true, "check": true, "generated": true, "collate": true, "deferrable": true, "initially": true,
+}
+
+// typeName consumes column type, e.g. character varying(20)[], and returns it as written
+// with unquoted names folded to lower case
+func (p *ddlParser) typeName() (string, error) {
+	var b strings.Builder
+	for !p.done() {
+		tok := p.peek()
+		switch {
+		case tok.kind == tokenWord && strings.EqualFold(tok.text, "array"):
+			// integer ARRAY[3] is integer[]
+			p.pos++
+			if p.acceptSymbol("[") {
+				p.until(func() bool { return p.isSymbol("]") })
+				p.acceptSymbol("]")
+			}
+			b.WriteString("[]")
+		case tok.kind == tokenWord && !columnConstraintWords[strings.ToLower(tok.text)],
+			tok.kind == tokenQuoted:
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), ".") {
+				b.WriteByte(' ')
+			}
+			b.WriteString(unquoteIdent(tok.text))
+			p.pos++
+		case tok.kind == tokenSymbol && tok.text == "." && b.Len() > 0:
+			b.WriteByte('.')
+			p.pos++
+		case tok.kind == tokenSymbol && tok.text == "(" && b.Len() > 0:
+			inner, err := p.group()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString("(" + p.text(inner) + ")")
+		case tok.kind == tokenSymbol && tok.text == "[" && b.Len() > 0:
+			p.pos++
+			p.until(func() bool { return p.isSymbol("]") })
+			if !p.acceptSymbol("]") {
+				return "", fmt.Errorf("unbalanced brackets in type")
+			}
+			b.WriteString("[]")
+		default:
+			if b.Len() == 0 {
+				return "", fmt.Errorf("expected type, got %s", p.describeNext())
+			}
+			return b.String(), nil
+		}
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("expected type, got end of statement")
+	}
+	return b.String(), nil
+}
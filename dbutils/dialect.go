@@ -0,0 +1,154 @@
+package dbutils
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ConnConfig - parameters of connection to the database
+type ConnConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	DBName   string
+	File     string // database file of SQLite
+}
+
+// Dialect - database specific parts of introspection and data generation
+type Dialect interface {
+	// Name returns name of the dialect used by --driver
+	Name() string
+	// DriverName returns name of the database/sql driver
+	DriverName() string
+	// DataSourceName returns connection string of the driver, or error if a required parameter is missing
+	DataSourceName(conn ConnConfig) (string, error)
+	DefaultPort() int
+	// Introspect reads tables of schemas passing the filter with their columns, types and constraints
+	Introspect(db *sql.DB, filter SchemaFilter) ([]Table, error)
+	// ColumnType converts type of a column as the database reports it to data type.
+	// The check holds range of the type which the data type doesn't express, e.g. of unsigned integers.
+	ColumnType(columnType string) (DataType, ColumnCheck, error)
+	// Placeholder returns bind parameter number n, counting from 1
+	Placeholder(n int) string
+	// QuoteIdent quotes a table or column name, so reserved words, mixed case and special characters are kept
+	QuoteIdent(name string) string
+	// RandomOrder returns ORDER BY expression sorting rows randomly
+	RandomOrder() string
+	// Returning reports whether INSERT ... RETURNING is supported. Otherwise values of inserted keys are taken
+	// from generated values and LastInsertId of the auto-increment column.
+	Returning() bool
+	// Copy reports whether rows can be loaded by COPY FROM STDIN
+	Copy() bool
+	// MaxParams returns the maximum number of bind parameters of one statement
+	MaxParams() int
+	// DefaultRow returns the end of INSERT writing one row of column defaults, e.g. DEFAULT VALUES
+	DefaultRow() string
+}
+
+// quoteTable returns the quoted table name prefixed with its quoted schema
+func quoteTable(dialect Dialect, schema, name string) string {
+	if schema == "" {
+		return dialect.QuoteIdent(name)
+	}
+	return dialect.QuoteIdent(schema) + "." + dialect.QuoteIdent(name)
+}
+
+// quoteIdents returns quoted names
+func quoteIdents(dialect Dialect, names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = dialect.QuoteIdent(name)
+	}
+	return quoted
+}
+
+// DialectByName returns dialect of --driver, empty name means PostgreSQL
+func DialectByName(name string) (Dialect, error) {
+	switch strings.ToLower(name) {
+	case "", "postgres", "postgresql":
+		return PostgresDialect{}, nil
+	case "mysql", "mariadb":
+		return MySQLDialect{}, nil
+	case "sqlite", "sqlite3":
+		return SQLiteDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unknown driver %s, expected postgres, mysql or sqlite", name)
+	}
+}
+
+// PostgresDialect - PostgreSQL through lib/pq
+type PostgresDialect struct{}
+
+func (PostgresDialect) Name() string {
+	return "postgres"
+}
+
+func (PostgresDialect) DriverName() string {
+	return "postgres"
+}
+
+func (d PostgresDialect) DataSourceName(conn ConnConfig) (string, error) {
+	if err := requireServerParams(d, conn); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		quoteConnValue(conn.Host), conn.Port, quoteConnValue(conn.User), quoteConnValue(conn.Password),
+		quoteConnValue(conn.DBName)), nil
+}
+
+// quoteConnValue quotes value of a key=value connection string, so it can be empty or contain spaces
+func quoteConnValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// requireServerParams checks parameters required to connect to a database server
+func requireServerParams(d Dialect, conn ConnConfig) error {
+	if conn.User == "" || conn.DBName == "" {
+		return fmt.Errorf("%s needs user and database name", d.Name())
+	}
+	return nil
+}
+
+func (PostgresDialect) DefaultPort() int {
+	return 5432
+}
+
+func (PostgresDialect) Introspect(db *sql.DB, filter SchemaFilter) ([]Table, error) {
+	return GetTablesWithDependencies(db, filter)
+}
+
+func (PostgresDialect) ColumnType(columnType string) (DataType, ColumnCheck, error) {
+	dataType, err := StringToDataType(columnType)
+	return dataType, ColumnCheck{}, err
+}
+
+func (PostgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (PostgresDialect) QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (PostgresDialect) RandomOrder() string {
+	return "RANDOM()"
+}
+
+func (PostgresDialect) Returning() bool {
+	return true
+}
+
+func (PostgresDialect) Copy() bool {
+	return true
+}
+
+func (PostgresDialect) MaxParams() int {
+	return 65535
+}
+
+func (PostgresDialect) DefaultRow() string {
+	return "DEFAULT VALUES"
+}
@@ -0,0 +1,76 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDialectByName(t *testing.T) {
+	for name, expected := range map[string]string{"": "postgres", "PostgreSQL": "postgres", "mysql": "mysql", "mariadb": "mysql"} {
+		dialect, err := DialectByName(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, dialect.Name())
+	}
+	_, err := DialectByName("oracle")
+	assert.ErrorContains(t, err, "unknown driver oracle")
+}
+
+func TestDialect_PlaceholderAndQuoting(t *testing.T) {
+	assert.Equal(t, "$3", PostgresDialect{}.Placeholder(3))
+	assert.Equal(t, "?", MySQLDialect{}.Placeholder(3))
+	assert.Equal(t, `"Order ""items"""`, PostgresDialect{}.QuoteIdent(`Order "items"`))
+	assert.Equal(t, "`Order ``items```", MySQLDialect{}.QuoteIdent("Order `items`"))
+}
+
+func TestInsertedKey(t *testing.T) {
+	table := Table{Schema: "shop", Name: "orders", Columns: map[string]Column{
+		"id":   {Name: "id", IsIdentity: true},
+		"code": {Name: "code"},
+		"uuid": {Name: "uuid", Default: "uuid()"},
+	}}
+	columns := []Column{table.Columns["code"]}
+
+	assert.NoError(t, checkInsertedKey(table, columns, []string{"id", "code"}))
+	assert.ErrorContains(t, checkInsertedKey(table, columns, []string{"uuid"}), "shop.orders.uuid")
+
+	key, err := insertedKey(insertResult(42), columns, []interface{}{"A-1"}, []string{"id", "code"})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(42), "A-1"}, key)
+}
+
+// insertResult - sql.Result with LastInsertId
+type insertResult int64
+
+func (r insertResult) LastInsertId() (int64, error) {
+	return int64(r), nil
+}
+
+func (r insertResult) RowsAffected() (int64, error) {
+	return 1, nil
+}
+
+func TestPostgresDialect_DataSourceName(t *testing.T) {
+	dsn, err := PostgresDialect{}.DataSourceName(ConnConfig{Host: "localhost", Port: 5432, User: "postgres", DBName: "shop"})
+	assert.NoError(t, err)
+	assert.Equal(t, "host='localhost' port=5432 user='postgres' password='' dbname='shop' sslmode=disable", dsn)
+
+	_, err = PostgresDialect{}.DataSourceName(ConnConfig{Host: "localhost", Port: 5432})
+	assert.ErrorContains(t, err, "postgres needs user and database name")
+}
+
+func TestInsertStatement_Quoting(t *testing.T) {
+	table := Table{Schema: "Shop", Name: `order "items"`}
+	columns := []string{"user", "CreatedAt", "unit price"}
+	assert.Equal(t, `INSERT INTO "Shop"."order ""items""" ("user", "CreatedAt", "unit price") VALUES ($1, $2, $3)`,
+		insertStatement(PostgresDialect{}, table, columns, []string{"($1, $2, $3)"}))
+	assert.Equal(t, "INSERT INTO `Shop`.`order \"items\"` (`user`, `CreatedAt`, `unit price`) VALUES (?, ?, ?)",
+		insertStatement(MySQLDialect{}, table, columns, []string{"(?, ?, ?)"}))
+	assert.Equal(t, `"order"`, quoteTable(SQLiteDialect{}, "", "order"))
+}
+
+func TestInsertStatement_DefaultRow(t *testing.T) {
+	table := Table{Schema: "shop", Name: "tickets"}
+	assert.Equal(t, `INSERT INTO "shop"."tickets" DEFAULT VALUES`, insertStatement(PostgresDialect{}, table, nil, []string{"()"}))
+	assert.Equal(t, `INSERT INTO "tickets" DEFAULT VALUES`, insertStatement(SQLiteDialect{}, Table{Name: "tickets"}, nil, []string{"()"}))
+	assert.Equal(t, "INSERT INTO `shop`.`tickets` () VALUES ()", insertStatement(MySQLDialect{}, table, nil, []string{"()"}))
+}
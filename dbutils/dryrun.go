@@ -0,0 +1,112 @@
+package dbutils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DryRunResult - rows generated for a table by DryRun
+type DryRunResult struct {
+	Table  Table
+	Rows   int    // number of generated rows
+	Sample string // first generated row, e.g. email=a@example.com, name=Ann
+	Err    error
+}
+
+// dryRunRows - sample of rows generated for a table, foreign keys of later tables take keys from it
+type dryRunRows struct {
+	table   Table
+	columns []Column
+	pool    *keyPool // generated values followed by the row number
+}
+
+// DryRun generates rows of tables without a database, tables must be sorted by TopologicalSort.
+// Rules and unique constraints are checked as when inserting, but nothing is written.
+// Foreign keys take keys of rows generated for the referenced table, keys filled by the database
+// (serial, identity) are numbered from 1. Keys of tables which are not generated are left NULL.
+// Tables with tree rule get the same hierarchy as when inserting.
+func DryRun(tables []Table) []DryRunResult {
+	generated := make(map[string]*dryRunRows)
+	results := make([]DryRunResult, 0, len(tables))
+	for _, table := range tables {
+		rows := &dryRunRows{table: table, columns: insertColumns(table), pool: newKeyPool(keyPoolSize)}
+		result := DryRunResult{Table: table}
+		result.Rows, result.Sample, result.Err = rows.generate(generated)
+		generated[table.QualifiedName()] = rows
+		results = append(results, result)
+	}
+	return results
+}
+
+// generate generates table.RowNum rows, returns the number of rows and the first one
+func (r *dryRunRows) generate(generated map[string]*dryRunRows) (int, string, error) {
+	tracker, err := newUniqueTracker(nil, nil, r.table, r.columns)
+	if err != nil {
+		return 0, "", err
+	}
+	keys := newKeyPools(nil, nil)
+	for _, fk := range r.table.ForeignKeys {
+		if parent, ok := generated[fk.RefQualifiedName()]; ok {
+			keys.pools[poolName(fk)] = parent.keyPool(fk.RefColumns)
+		}
+	}
+
+	var tree *treeState
+	if r.table.Tree != nil {
+		if tree, err = newTreeState(r.table.Tree, r.table.RowNum, r.columns); err != nil {
+			return 0, "", fmt.Errorf("%s: %v", r.table.QualifiedName(), err)
+		}
+	}
+
+	sample := ""
+	for i := 0; i < r.table.RowNum; i++ {
+		values, err := generateUniqueRow(keys, tracker, r.table, r.columns, tree, i)
+		if err != nil {
+			return i, sample, err
+		}
+		if tree != nil && tree.remember(i, r.key(tree.tree.ForeignKey.RefColumns, values, int64(i+1))) && tree.pathColumn >= 0 {
+			// written by an UPDATE after insertion
+			values[tree.pathColumn] = tree.paths[i]
+		}
+		if i == 0 {
+			sample = formatRow(r.columns, values)
+		}
+		r.pool.add(append(values, int64(i+1)))
+	}
+	return r.table.RowNum, sample, nil
+}
+
+// keyPool returns values of the columns of generated rows, columns filled by the database get the row number
+func (r *dryRunRows) keyPool(columns []string) *keyPool {
+	pool := newKeyPool(keyPoolSize)
+	for _, row := range r.pool.rows {
+		pool.add(r.key(columns, row, row[len(row)-1]))
+	}
+	return pool
+}
+
+// key returns values of the columns of a generated row, columns filled by the database get the row number
+func (r *dryRunRows) key(columns []string, values []interface{}, number interface{}) []interface{} {
+	key := make([]interface{}, len(columns))
+	for j, name := range columns {
+		if k := columnIndex(r.columns, name); k >= 0 {
+			key[j] = values[k]
+		} else if col := r.table.Columns[name]; col.IsIdentity || col.Default != "" {
+			key[j] = number
+		}
+	}
+	return key
+}
+
+// formatRow returns name=value pairs of the row
+func formatRow(columns []Column, values []interface{}) string {
+	pairs := make([]string, len(columns))
+	for i, col := range columns {
+		value := "NULL"
+		if values[i] != nil {
+			value = valueString(values[i])
+		}
+		pairs[i] = fmt.Sprintf("%s=%s", col.Name, value)
+	}
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,88 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/victornguen/db-faker/datagen"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.sql")
+	ddl := `
+CREATE TABLE users (
+    id     serial PRIMARY KEY,
+    active boolean NOT NULL UNIQUE
+);
+CREATE TABLE orders (
+    id      bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id int NOT NULL REFERENCES users (id),
+    note    text
+);`
+	assert.NoError(t, os.WriteFile(path, []byte(ddl), 0644))
+	tables, _, err := ParseDDL(path, SchemaFilter{})
+	assert.NoError(t, err)
+	tables = TopologicalSort(tables)
+	rules := datagen.TablesRules{Rules: map[string]datagen.TableRule{
+		"users":  {RowNum: 2},
+		"orders": {RowNum: 20},
+	}}
+	assert.NoError(t, ApplyRulesToTables(&tables, rules))
+
+	results := DryRun(tables)
+	assert.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, 2, results[0].Rows)
+	assert.Contains(t, results[0].Sample, "active=")
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, 20, results[1].Rows)
+
+	// orders reference users numbered from 1 as serial would number them
+	assert.Regexp(t, `user_id=[12](,|$)`, results[1].Sample)
+
+	// a boolean has only two unique values
+	tables[0].RowNum = 3
+	results = DryRun(tables)
+	assert.Error(t, results[0].Err)
+	assert.Equal(t, 2, results[0].Rows)
+	// orders reference the two generated users
+	assert.NoError(t, results[1].Err)
+
+	tables[0].RowNum = 0
+	results = DryRun(tables[1:])
+	// users are not generated, their keys would be read from the database
+	assert.NoError(t, results[0].Err)
+	assert.Contains(t, results[0].Sample, "user_id=NULL")
+}
+
+func TestDryRun_Tree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.sql")
+	ddl := `
+CREATE TABLE categories (
+    id        serial PRIMARY KEY,
+    parent_id int REFERENCES categories (id),
+    depth     int,
+    path      text
+);`
+	assert.NoError(t, os.WriteFile(path, []byte(ddl), 0644))
+	tables, _, err := ParseDDL(path, SchemaFilter{})
+	assert.NoError(t, err)
+	tables = TopologicalSort(tables)
+	rules := datagen.TablesRules{Rules: map[string]datagen.TableRule{
+		"categories": {RowNum: 7, Tree: &datagen.TreeRule{Fanout: "fixed(2)", PathColumn: "path", DepthColumn: "depth"}},
+	}}
+	assert.NoError(t, ApplyRulesToTables(&tables, rules))
+
+	results := DryRun(tables)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "depth=0, parent_id=NULL, path=1", results[0].Sample)
+
+	rows := &dryRunRows{table: tables[0], columns: insertColumns(tables[0]), pool: newKeyPool(keyPoolSize)}
+	_, _, err = rows.generate(map[string]*dryRunRows{})
+	assert.NoError(t, err)
+	// with fixed(2) row 4 is a child of row 2, a child of the root 1, ids are numbered as serial would number them
+	row := rows.pool.rows[3]
+	assert.Equal(t, int64(4), row[len(row)-1])
+	assert.Equal(t, []interface{}{2, int64(2), "1/2/4"}, row[:3])
+}
@@ -0,0 +1,245 @@
+package dbutils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Cycle - tables whose foreign keys form a cycle
+type Cycle struct {
+	Tables    []string     // qualified names in insertion order
+	BackEdges []ForeignKey // foreign keys filled by UPDATE after the referenced table is inserted
+	// Unresolved - NOT NULL non-deferrable back edges, the cycle can't be inserted because of them
+	Unresolved []ForeignKey
+}
+
+// TopologicalSort orders tables so that referenced tables come first.
+// Tables of a foreign key cycle are kept together, ordered by their NOT NULL non-deferrable foreign keys,
+// the remaining foreign keys of the cycle are marked with BackFill.
+func TopologicalSort(tables []Table) []Table {
+	var sorted []Table
+	for _, component := range stronglyConnected(tables) {
+		sorted = append(sorted, orderComponent(component)...)
+	}
+	return markBackEdges(sorted)
+}
+
+// FindCycles returns foreign key cycles of tables sorted by TopologicalSort.
+// Self-references generated as trees are not cycles.
+func FindCycles(sorted []Table) []Cycle {
+	cycles := make([]Cycle, 0)
+	for _, component := range stronglyConnected(sorted) {
+		if len(component) == 1 && (!dependsOnItself(component[0]) || isTree(component[0])) {
+			continue
+		}
+		var cycle Cycle
+		for _, table := range component {
+			cycle.Tables = append(cycle.Tables, table.QualifiedName())
+			for _, fk := range table.ForeignKeys {
+				if !fk.BackFill {
+					continue
+				}
+				if fk.Deferrable || foreignKeyNullable(table, fk) {
+					cycle.BackEdges = append(cycle.BackEdges, fk)
+				} else {
+					cycle.Unresolved = append(cycle.Unresolved, fk)
+				}
+			}
+		}
+		cycles = append(cycles, cycle)
+	}
+	return cycles
+}
+
+func (c Cycle) String() string {
+	edges := make([]string, 0, len(c.BackEdges))
+	for _, fk := range c.BackEdges {
+		kind := "nullable"
+		if fk.Deferrable {
+			kind = "deferrable"
+		}
+		edges = append(edges, fmt.Sprintf("%s -> %s (%s)", fk.Name, fk.RefQualifiedName(), kind))
+	}
+	msg := fmt.Sprintf("foreign key cycle between %s, filled after insert: %s",
+		strings.Join(c.Tables, ", "), strings.Join(edges, ", "))
+	if len(c.Unresolved) > 0 {
+		unresolved := make([]string, 0, len(c.Unresolved))
+		for _, fk := range c.Unresolved {
+			unresolved = append(unresolved, fmt.Sprintf("%s -> %s", fk.Name, fk.RefQualifiedName()))
+		}
+		msg += fmt.Sprintf("; NOT NULL and not deferrable, the cycle can't be inserted: %s",
+			strings.Join(unresolved, ", "))
+	}
+	return msg
+}
+
+// InsertGroups splits sorted tables into groups inserted together:
+// a single table, or all tables of a foreign key cycle
+func InsertGroups(sorted []Table) [][]Table {
+	component := make(map[string]int)
+	for i, c := range stronglyConnected(sorted) {
+		for _, table := range c {
+			component[table.QualifiedName()] = i
+		}
+	}
+
+	groups := make([][]Table, 0)
+	for i, table := range sorted {
+		if i > 0 && component[table.QualifiedName()] == component[sorted[i-1].QualifiedName()] {
+			groups[len(groups)-1] = append(groups[len(groups)-1], table)
+			continue
+		}
+		groups = append(groups, []Table{table})
+	}
+	return groups
+}
+
+// stronglyConnected returns strongly connected components of the dependency graph (Tarjan's algorithm).
+// Every component comes after the components it depends on, tables inside a component keep input order.
+func stronglyConnected(tables []Table) [][]Table {
+	byName := make(map[string]Table, len(tables))
+	order := make(map[string]int, len(tables))
+	for i, t := range tables {
+		byName[t.QualifiedName()] = t
+		order[t.QualifiedName()] = i
+	}
+
+	index := 0
+	indices := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	stack := make([]string, 0)
+	components := make([][]Table, 0)
+
+	var connect func(name string)
+	connect = func(name string) {
+		indices[name] = index
+		low[name] = index
+		index++
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, dep := range byName[name].DependsOn {
+			if _, ok := byName[dep]; !ok {
+				continue
+			}
+			if _, visited := indices[dep]; !visited {
+				connect(dep)
+				low[name] = min(low[name], low[dep])
+			} else if onStack[dep] {
+				low[name] = min(low[name], indices[dep])
+			}
+		}
+
+		if low[name] == indices[name] {
+			component := make([]Table, 0)
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, byName[top])
+				if top == name {
+					break
+				}
+			}
+			// keep input order inside the component
+			for i := 1; i < len(component); i++ {
+				for j := i; j > 0 && order[component[j].QualifiedName()] < order[component[j-1].QualifiedName()]; j-- {
+					component[j], component[j-1] = component[j-1], component[j]
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, t := range tables {
+		if _, visited := indices[t.QualifiedName()]; !visited {
+			connect(t.QualifiedName())
+		}
+	}
+	return components
+}
+
+// orderComponent orders tables of a cycle so that NOT NULL non-deferrable foreign keys point to earlier tables
+func orderComponent(component []Table) []Table {
+	if len(component) == 1 {
+		return component
+	}
+	byName := make(map[string]Table, len(component))
+	for _, t := range component {
+		byName[t.QualifiedName()] = t
+	}
+
+	var sorted []Table
+	visited := make(map[string]bool)
+	var visit func(table Table)
+	visit = func(table Table) {
+		if visited[table.QualifiedName()] {
+			return
+		}
+		visited[table.QualifiedName()] = true
+		for _, fk := range table.ForeignKeys {
+			ref, ok := byName[fk.RefQualifiedName()]
+			if ok && !fk.Deferrable && !foreignKeyNullable(table, fk) {
+				visit(ref)
+			}
+		}
+		sorted = append(sorted, table)
+	}
+	for _, table := range component {
+		visit(table)
+	}
+	return sorted
+}
+
+// markBackEdges sets BackFill on foreign keys referencing the same or a later table
+func markBackEdges(sorted []Table) []Table {
+	position := make(map[string]int, len(sorted))
+	for i, t := range sorted {
+		position[t.QualifiedName()] = i
+	}
+	for i, table := range sorted {
+		fks := make([]ForeignKey, len(table.ForeignKeys))
+		for j, fk := range table.ForeignKeys {
+			refPos, ok := position[fk.RefQualifiedName()]
+			fk.BackFill = ok && refPos >= i
+			fks[j] = fk
+		}
+		sorted[i].ForeignKeys = fks
+	}
+	return sorted
+}
+
+func dependsOnItself(table Table) bool {
+	for _, dep := range table.DependsOn {
+		if dep == table.QualifiedName() {
+			return true
+		}
+	}
+	return false
+}
+
+// isTree reports whether the table references itself only through its tree foreign key
+func isTree(table Table) bool {
+	return table.Tree != nil && !hasBackFill(table)
+}
+
+// foreignKeyNullable reports whether all columns of the foreign key are nullable
+func foreignKeyNullable(table Table, fk ForeignKey) bool {
+	for _, colName := range fk.Columns {
+		if !table.Columns[colName].IsNullable {
+			return false
+		}
+	}
+	return true
+}
+
+// hasBackFill reports whether some foreign keys of the table are filled after insertion
+func hasBackFill(table Table) bool {
+	for _, fk := range table.ForeignKeys {
+		if fk.BackFill {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func cycleTable(name, refTable string, nullable, deferrable bool) Table {
+	return Table{
+		Schema: "public",
+		Name:   name,
+		Columns: map[string]Column{
+			"id":     {Name: "id"},
+			"ref_id": {Name: "ref_id", IsNullable: nullable, IsForeignKey: true},
+		},
+		DependsOn:   []string{QualifyName("public", refTable)},
+		PrimaryKeys: map[string]bool{"id": true},
+		ForeignKeys: []ForeignKey{{
+			Name:       name + "_ref_fk",
+			Columns:    []string{"ref_id"},
+			RefSchema:  "public",
+			RefTable:   refTable,
+			RefColumns: []string{"id"},
+			Deferrable: deferrable,
+		}},
+	}
+}
+
+func TestTopologicalSort_Cycle(t *testing.T) {
+	tables := []Table{
+		{Schema: "public", Name: "orders", DependsOn: []string{"public.a"}},
+		cycleTable("a", "b", true, false),
+		cycleTable("b", "a", false, false),
+	}
+	sorted := TopologicalSort(tables)
+
+	names := make([]string, 0)
+	for _, table := range sorted {
+		names = append(names, table.QualifiedName())
+	}
+	// b.ref_id is NOT NULL, so a is inserted first and a.ref_id is filled after b
+	assert.Equal(t, []string{"public.a", "public.b", "public.orders"}, names)
+	assert.True(t, sorted[0].ForeignKeys[0].BackFill)
+	assert.False(t, sorted[1].ForeignKeys[0].BackFill)
+
+	cycles := FindCycles(sorted)
+	assert.Len(t, cycles, 1)
+	assert.Equal(t, []string{"public.a", "public.b"}, cycles[0].Tables)
+	assert.Len(t, cycles[0].BackEdges, 1)
+	assert.Empty(t, cycles[0].Unresolved)
+
+	groups := InsertGroups(sorted)
+	assert.Len(t, groups, 2)
+	assert.Len(t, groups[0], 2)
+	assert.Equal(t, "public.orders", groups[1][0].QualifiedName())
+}
+
+func TestTopologicalSort_SelfReference(t *testing.T) {
+	sorted := TopologicalSort([]Table{cycleTable("employees", "employees", true, false)})
+	assert.True(t, sorted[0].ForeignKeys[0].BackFill)
+
+	cycles := FindCycles(sorted)
+	assert.Len(t, cycles, 1)
+	assert.Equal(t, []string{"public.employees"}, cycles[0].Tables)
+}
+
+func TestFindCycles_Unresolved(t *testing.T) {
+	sorted := TopologicalSort([]Table{
+		cycleTable("a", "b", false, false),
+		cycleTable("b", "a", false, true),
+	})
+	cycles := FindCycles(sorted)
+	assert.Len(t, cycles, 1)
+	assert.Len(t, cycles[0].BackEdges, 1)
+	assert.True(t, cycles[0].BackEdges[0].Deferrable)
+	assert.Empty(t, cycles[0].Unresolved)
+
+	sorted = TopologicalSort([]Table{
+		cycleTable("a", "b", false, false),
+		cycleTable("b", "a", false, false),
+	})
+	cycles = FindCycles(sorted)
+	assert.Len(t, cycles[0].Unresolved, 1)
+	assert.Contains(t, cycles[0].String(), "can't be inserted")
+}
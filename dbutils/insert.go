@@ -0,0 +1,500 @@
+package dbutils
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Querier - common methods of *sql.DB and *sql.Tx
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+// pickReferencedRow selects values of fk.RefColumns from one random row of the referenced table by a query,
+// it is used when a key pool has only a sample of the referenced rows.
+// Columns already set in values (by another foreign key) narrow the choice.
+func pickReferencedRow(db Querier, dialect Dialect, fk ForeignKey, values map[string]interface{}) ([]interface{}, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	for i, colName := range fk.Columns {
+		if v, ok := values[colName]; ok && v != nil {
+			args = append(args, v)
+			conditions = append(conditions, fmt.Sprintf("%s = %s",
+				dialect.QuoteIdent(fk.RefColumns[i]), dialect.Placeholder(len(args))))
+		}
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT 1", strings.Join(quoteIdents(dialect, fk.RefColumns), ", "),
+		quoteTable(dialect, fk.RefSchema, fk.RefTable), where, dialect.RandomOrder())
+
+	refValues := make([]interface{}, len(fk.RefColumns))
+	dest := make([]interface{}, len(fk.RefColumns))
+	for i := range refValues {
+		dest[i] = &refValues[i]
+	}
+	if err := db.QueryRow(query, args...).Scan(dest...); err != nil {
+		return nil, err
+	}
+	return refValues, nil
+}
+
+// generateNull decides whether the next value of the column is NULL
+func (c Column) generateNull() bool {
+	return c.IsNullable && c.NullPercent > 0 && rand.Intn(100) < c.NullPercent
+}
+
+// foreignKeyIsNull decides whether the foreign key is left NULL in the next row.
+// Only foreign keys with all columns nullable and not yet set can be NULL,
+// the probability is taken from the first column.
+func foreignKeyIsNull(table Table, fk ForeignKey, values map[string]interface{}) bool {
+	for _, colName := range fk.Columns {
+		if _, ok := values[colName]; ok {
+			return false
+		}
+	}
+	return foreignKeyNullable(table, fk) && table.Columns[fk.Columns[0]].generateNull()
+}
+
+// generateRow generates values of columns for one row, values of preset columns are taken as is
+func generateRow(keys *keyPools, table Table, columns []Column, preset map[string]interface{}) ([]interface{}, error) {
+	// Take all columns of a foreign key from one referenced row
+	fkValues := make(map[string]interface{})
+	for colName, v := range preset {
+		fkValues[colName] = v
+	}
+	for _, fk := range table.ForeignKeys {
+		if isTreeForeignKey(table, fk) {
+			continue
+		}
+		if fk.BackFill {
+			// referenced rows don't exist yet: NULL, or a placeholder if the constraint is deferred
+			nullable := foreignKeyNullable(table, fk)
+			for _, colName := range fk.Columns {
+				if _, ok := fkValues[colName]; !ok {
+					if nullable {
+						fkValues[colName] = nil
+					} else {
+						fkValues[colName] = table.Columns[colName].DataGen()
+					}
+				}
+			}
+			continue
+		}
+		if foreignKeyIsNull(table, fk, fkValues) {
+			for _, colName := range fk.Columns {
+				if _, ok := fkValues[colName]; !ok {
+					fkValues[colName] = nil
+				}
+			}
+			continue
+		}
+		refValues, err := keys.pick(fk, fkValues)
+		if err != nil {
+			return nil, fmt.Errorf("no reference data found in %s for %s (%s): %v",
+				fk.RefQualifiedName(), table.QualifiedName(), strings.Join(fk.Columns, ", "), err)
+		}
+		for j, colName := range fk.Columns {
+			if _, ok := fkValues[colName]; !ok {
+				fkValues[colName] = refValues[j]
+			}
+		}
+	}
+
+	values := make([]interface{}, len(columns))
+	for j, col := range columns {
+		if v, ok := fkValues[col.Name]; ok {
+			values[j] = v
+		} else if col.generateNull() {
+			values[j] = nil
+		} else {
+			values[j] = col.DataGen()
+		}
+	}
+	return values, nil
+}
+
+// generateUniqueRow generates row i, it is regenerated until it doesn't repeat values of unique constraints.
+// With tree the row gets the key of its parent, depth and path.
+func generateUniqueRow(keys *keyPools, tracker *uniqueTracker, table Table, columns []Column,
+	tree *treeState, i int) ([]interface{}, error) {
+	var preset map[string]interface{}
+	if tree != nil {
+		preset = tree.values(i)
+	}
+	for attempt := 1; ; attempt++ {
+		values, err := generateRow(keys, table, columns, preset)
+		if err != nil {
+			return nil, err
+		}
+		if tree != nil {
+			// the path written by the INSERT is checked for uniqueness, not its placeholder
+			tree.generated(i, values)
+		}
+		constraint, ok := tracker.add(values)
+		if ok {
+			return values, nil
+		}
+		if attempt >= uniqueRetryLimit {
+			return nil, fmt.Errorf("can't generate unique values for %s (%s) after %d attempts, "+
+				"generated %d of %d rows: value space of the generators is too small",
+				table.QualifiedName(), strings.Join(constraint.Columns, ", "), uniqueRetryLimit, i, table.RowNum)
+		}
+	}
+}
+
+// primaryKeyColumns returns primary key column names in stable order
+func primaryKeyColumns(table Table) []string {
+	pkCols := make([]string, 0, len(table.PrimaryKeys))
+	for colName, isPK := range table.PrimaryKeys {
+		if isPK {
+			pkCols = append(pkCols, colName)
+		}
+	}
+	sort.Strings(pkCols)
+	return pkCols
+}
+
+// GenerateAndInsertData generates and inserts rows of the table, returns the number of inserted rows
+func GenerateAndInsertData(s *Session, opts LoadOptions, table Table) (int, error) {
+	_, inserted, err := insertRows(s, opts, table)
+	return inserted, err
+}
+
+// insertColumns returns columns written by INSERT in stable order: primary key columns with a default
+// are left out, unless they are filled from a foreign key, and so are columns filled by the database
+func insertColumns(table Table) []Column {
+	var columns []Column
+	for _, col := range table.Columns {
+		if col.skipInInsert() {
+			continue
+		}
+		if !table.PrimaryKeys[col.Name] || col.IsForeignKey || col.Default == "" {
+			columns = append(columns, col)
+		}
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+	return columns
+}
+
+// insertRows generates and inserts all rows of the table, see insertPart
+func insertRows(s *Session, opts LoadOptions, table Table) ([][]interface{}, int, error) {
+	return insertPart(s, opts, tablePart{table: table, rows: table.RowNum})
+}
+
+// insertPart generates and inserts rows of a table part, returns the number of inserted rows.
+// If the table has foreign keys filled after insertion, primary keys of inserted rows are returned.
+// Rows of a table with tree are inserted parents first, each row gets the key of its parent.
+// Rows are inserted one by one when their keys are needed, otherwise in chunks of the load method.
+// Failed rows are reported and skipped, in an atomic session they fail the table.
+func insertPart(s *Session, opts LoadOptions, part tablePart) ([][]interface{}, int, error) {
+	dialect := opts.Dialect
+	table := part.table
+	filteredColumns := insertColumns(table)
+
+	// Prepare column names and placeholders for non-PK columns
+	columns := make([]string, 0, len(filteredColumns))
+	placeholders := make([]string, 0, len(filteredColumns))
+	for i, col := range filteredColumns {
+		columns = append(columns, col.Name)
+		placeholders = append(placeholders, dialect.Placeholder(i+1))
+	}
+
+	query := insertStatement(dialect, table, columns, []string{"(" + strings.Join(placeholders, ", ") + ")"})
+
+	var err error
+	var pkCols []string
+	returning := make([]string, 0)
+	if hasBackFill(table) {
+		pkCols = primaryKeyColumns(table)
+		if len(pkCols) == 0 {
+			return nil, 0, fmt.Errorf("table %s has no primary key, its foreign keys can't be filled after insert",
+				table.QualifiedName())
+		}
+		returning = append(returning, pkCols...)
+	}
+	var tree *treeState
+	if table.Tree != nil {
+		tree, err = newTreeState(table.Tree, table.RowNum, filteredColumns)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %v", table.QualifiedName(), err)
+		}
+		returning = append(returning, table.Tree.ForeignKey.RefColumns...)
+	}
+	if len(returning) > 0 && dialect.Returning() {
+		query += " RETURNING " + strings.Join(quoteIdents(dialect, returning), ", ")
+	}
+	if len(returning) > 0 && !dialect.Returning() {
+		if err := checkInsertedKey(table, filteredColumns, returning); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	chunkRows := opts.chunkRows(len(columns))
+	if opts.Method == LoadInsert && len(columns) > 0 {
+		chunkRows = s.chunkRows()
+	}
+	if len(returning) > 0 {
+		chunkRows = 1
+	}
+
+	tracker := part.tracker
+	if tracker == nil {
+		if tracker, err = newUniqueTracker(s, dialect, table, filteredColumns); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	start := time.Now()
+	inserted := 0
+	pools := newKeyPools(s, dialect)
+	keys := make([][]interface{}, 0)
+	chunk := make([][]interface{}, 0, chunkRows)
+	end := part.first + part.rows
+	for i := part.first; i < end; i++ {
+		values, err := generateUniqueRow(pools, tracker, table, filteredColumns, tree, i)
+		if err != nil {
+			return nil, inserted, err
+		}
+
+		if chunkRows > 1 {
+			chunk = append(chunk, values)
+			if len(chunk) == chunkRows || i == end-1 {
+				chunkInserted, err := loadChunk(s, opts, table, columns, chunk, i+1-len(chunk))
+				inserted += chunkInserted
+				if err != nil {
+					return nil, inserted, err
+				}
+				chunk = chunk[:0]
+			}
+			continue
+		}
+
+		var returned []interface{}
+		err = s.write(func(q Querier) error {
+			stmt, err := s.prepare(query)
+			if err != nil {
+				return err
+			}
+			if len(returning) == 0 {
+				_, err = stmt.Exec(values...)
+				return err
+			}
+			if dialect.Returning() {
+				returned = make([]interface{}, len(returning))
+				dest := make([]interface{}, len(returning))
+				for j := range returned {
+					dest[j] = &returned[j]
+				}
+				err = stmt.QueryRow(values...).Scan(dest...)
+			} else {
+				var result sql.Result
+				result, err = stmt.Exec(values...)
+				if err == nil {
+					returned, err = insertedKey(result, filteredColumns, values, returning)
+				}
+			}
+			if err == nil && tree != nil {
+				err = tree.inserted(q, dialect, table, i, returned[len(pkCols):])
+			}
+			return err
+		})
+		if err != nil {
+			if s.Atomic() {
+				return nil, inserted, fmt.Errorf("row %d: %v", i, err)
+			}
+			fmt.Printf("Error inserting row %d into %s: %v\n", i, table.QualifiedName(), err)
+			continue
+		}
+		if len(pkCols) > 0 {
+			keys = append(keys, returned[:len(pkCols)])
+		}
+		inserted++
+		if err := s.written(table, 1); err != nil {
+			return nil, inserted, err
+		}
+	}
+
+	speed := RowsPerSecond(inserted, time.Since(start))
+	if part.rows == table.RowNum {
+		fmt.Printf("Inserted %d rows into %s (%.0f rows/s)\n", inserted, table.QualifiedName(), speed)
+	} else {
+		fmt.Printf("Inserted %d rows into %s, rows %d-%d (%.0f rows/s)\n",
+			inserted, table.QualifiedName(), part.first, end-1, speed)
+	}
+	return keys, inserted, nil
+}
+
+// checkInsertedKey checks that values of returned columns can be known without RETURNING:
+// they are written by the INSERT, or set by auto-increment
+func checkInsertedKey(table Table, columns []Column, returned []string) error {
+	for _, name := range returned {
+		if columnIndex(columns, name) < 0 && !table.Columns[name].IsIdentity {
+			return fmt.Errorf("%s.%s is filled by the database and can't be read back without RETURNING",
+				table.QualifiedName(), name)
+		}
+	}
+	return nil
+}
+
+// insertedKey returns values of returned columns of an inserted row for databases without RETURNING.
+// Values are taken from the written row, the auto-increment column gets LastInsertId.
+func insertedKey(result sql.Result, columns []Column, values []interface{}, returned []string) ([]interface{}, error) {
+	key := make([]interface{}, len(returned))
+	for i, name := range returned {
+		if j := columnIndex(columns, name); j >= 0 {
+			key[i] = values[j]
+			continue
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		key[i] = id
+	}
+	return key, nil
+}
+
+// columnIndex returns index of the column with name, or -1
+func columnIndex(columns []Column, name string) int {
+	for i, col := range columns {
+		if col.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// backFillForeignKeys sets foreign keys marked with BackFill in rows identified by primary keys
+func backFillForeignKeys(s *Session, dialect Dialect, table Table, keys [][]interface{}) error {
+	pkCols := primaryKeyColumns(table)
+	pools := newKeyPools(s, dialect)
+	updated := 0
+	for _, key := range keys {
+		values := make(map[string]interface{})
+		setCols := make([]string, 0)
+		for _, fk := range table.ForeignKeys {
+			if !fk.BackFill {
+				continue
+			}
+			if foreignKeyNullable(table, fk) && table.Columns[fk.Columns[0]].generateNull() {
+				continue
+			}
+			refValues, err := pools.pick(fk, values)
+			if err != nil {
+				return fmt.Errorf("no reference data found in %s for %s (%s): %v",
+					fk.RefQualifiedName(), table.QualifiedName(), strings.Join(fk.Columns, ", "), err)
+			}
+			for j, colName := range fk.Columns {
+				if _, ok := values[colName]; !ok {
+					values[colName] = refValues[j]
+					setCols = append(setCols, colName)
+				}
+			}
+		}
+		if len(setCols) == 0 {
+			continue
+		}
+
+		assignments := make([]string, 0, len(setCols))
+		args := make([]interface{}, 0, len(setCols)+len(pkCols))
+		for _, colName := range setCols {
+			args = append(args, values[colName])
+			assignments = append(assignments, fmt.Sprintf("%s = %s", dialect.QuoteIdent(colName), dialect.Placeholder(len(args))))
+		}
+		conditions := make([]string, 0, len(pkCols))
+		for j, colName := range pkCols {
+			args = append(args, key[j])
+			conditions = append(conditions, fmt.Sprintf("%s = %s", dialect.QuoteIdent(colName), dialect.Placeholder(len(args))))
+		}
+		query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", quoteTable(dialect, table.Schema, table.Name),
+			strings.Join(assignments, ", "), strings.Join(conditions, " AND "))
+		err := s.write(func(q Querier) error {
+			_, err := q.Exec(query, args...)
+			return err
+		})
+		if err != nil {
+			if s.Atomic() {
+				return fmt.Errorf("updating foreign keys of %s: %v", table.QualifiedName(), err)
+			}
+			fmt.Printf("Error updating foreign keys of %s: %v\n", table.QualifiedName(), err)
+			continue
+		}
+		updated++
+	}
+
+	fmt.Printf("Filled foreign keys of %d rows in %s\n", updated, table.QualifiedName())
+	return nil
+}
+
+// GenerateAndInsertGroup inserts data into a group of tables returned by InsertGroups,
+// returns the number of inserted rows.
+// Tables of a foreign key cycle are inserted with NULLs (or placeholders for deferrable constraints)
+// in back edges, which are filled by UPDATE when all tables of the cycle are inserted.
+// A cycle with NOT NULL deferrable back edges is inserted in one transaction.
+func GenerateAndInsertGroup(s *Session, opts LoadOptions, group []Table) (int, error) {
+	if len(group) == 1 && !hasBackFill(group[0]) {
+		return GenerateAndInsertData(s, opts, group[0])
+	}
+
+	deferred := false
+	for _, table := range group {
+		for _, fk := range table.ForeignKeys {
+			if !fk.BackFill || foreignKeyNullable(table, fk) {
+				continue
+			}
+			if !fk.Deferrable {
+				return 0, fmt.Errorf("foreign key %s of %s is NOT NULL and not deferrable, the cycle can't be inserted",
+					fk.Name, table.QualifiedName())
+			}
+			deferred = true
+		}
+	}
+
+	if !deferred {
+		return insertCycle(s, opts, group)
+	}
+
+	inserted := 0
+	err := s.deferred(func() error {
+		var err error
+		inserted, err = insertCycle(s, opts, group)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return inserted, nil
+}
+
+func insertCycle(s *Session, opts LoadOptions, group []Table) (int, error) {
+	inserted := 0
+	keys := make([][][]interface{}, len(group))
+	for i, table := range group {
+		tableKeys, tableInserted, err := insertRows(s, opts, table)
+		inserted += tableInserted
+		if err != nil {
+			return inserted, fmt.Errorf("%s: %v", table.QualifiedName(), err)
+		}
+		keys[i] = tableKeys
+	}
+	for i, table := range group {
+		if !hasBackFill(table) {
+			continue
+		}
+		if err := backFillForeignKeys(s, opts.Dialect, table, keys[i]); err != nil {
+			return inserted, err
+		}
+	}
+	return inserted, nil
+}
@@ -0,0 +1,167 @@
+package dbutils
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// keyPoolSize - maximum number of keys of a referenced table kept in memory, keys of larger tables are sampled
+const keyPoolSize = 100000
+
+// keyPool - sample of values of referenced columns of one table, foreign keys take random rows from it
+type keyPool struct {
+	size    int
+	rows    [][]interface{}
+	seen    int                                   // number of rows offered to the pool
+	indexes map[string]map[string][][]interface{} // rows by values of fixed columns, by positions of the columns
+}
+
+func newKeyPool(size int) *keyPool {
+	return &keyPool{size: size, rows: make([][]interface{}, 0), indexes: make(map[string]map[string][][]interface{})}
+}
+
+// add offers a row to the pool, reservoir sampling keeps a uniform sample of at most size rows
+func (p *keyPool) add(row []interface{}) {
+	p.seen++
+	clear(p.indexes)
+	if len(p.rows) < p.size {
+		p.rows = append(p.rows, row)
+		return
+	}
+	if j := rand.Intn(p.seen); j < p.size {
+		p.rows[j] = row
+	}
+}
+
+// sampled reports whether some rows of the table are not in the pool
+func (p *keyPool) sampled() bool {
+	return p.seen > len(p.rows)
+}
+
+// pick returns a random row with values of fixed columns, nil means any value of the column
+func (p *keyPool) pick(fixed []interface{}) ([]interface{}, bool) {
+	positions := make([]int, 0, len(fixed))
+	for i, v := range fixed {
+		if v != nil {
+			positions = append(positions, i)
+		}
+	}
+	rows := p.rows
+	if len(positions) > 0 {
+		rows = p.matching(positions, fixed)
+	}
+	if len(rows) == 0 {
+		return nil, false
+	}
+	return rows[rand.Intn(len(rows))], true
+}
+
+// matching returns rows with values of fixed columns at positions,
+// the pool is indexed by these columns on first use
+func (p *keyPool) matching(positions []int, fixed []interface{}) [][]interface{} {
+	name := fmt.Sprint(positions)
+	index, ok := p.indexes[name]
+	if !ok {
+		index = make(map[string][][]interface{})
+		for _, row := range p.rows {
+			key := indexKey(row, positions)
+			index[key] = append(index[key], row)
+		}
+		p.indexes[name] = index
+	}
+	return index[indexKey(fixed, positions)]
+}
+
+// indexKey returns text form of values of the row at positions
+func indexKey(row []interface{}, positions []int) string {
+	parts := make([]string, len(positions))
+	for i, pos := range positions {
+		parts[i] = valueString(row[pos])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// keyPools - key pools of referenced tables, each is loaded by one query on first use,
+// so rows are not selected by ORDER BY RANDOM() one by one.
+// Without db the pools are filled in advance, see DryRun.
+type keyPools struct {
+	db      Querier
+	dialect Dialect
+	pools   map[string]*keyPool // by referenced table and columns
+}
+
+func newKeyPools(db Querier, dialect Dialect) *keyPools {
+	return &keyPools{db: db, dialect: dialect, pools: make(map[string]*keyPool)}
+}
+
+// pick selects values of fk.RefColumns from one random referenced row.
+// Columns already set in values (by another foreign key) narrow the choice, so overlapping
+// foreign keys get consistent values.
+func (k *keyPools) pick(fk ForeignKey, values map[string]interface{}) ([]interface{}, error) {
+	pool, ok := k.pools[poolName(fk)]
+	if !ok && k.db == nil {
+		// dry run: the referenced table is not generated, its keys would be read from the database
+		return make([]interface{}, len(fk.RefColumns)), nil
+	}
+	if !ok {
+		var err error
+		if pool, err = loadKeyPool(k.db, k.dialect, fk, keyPoolSize); err != nil {
+			return nil, err
+		}
+		k.pools[poolName(fk)] = pool
+	}
+
+	fixed := make([]interface{}, len(fk.Columns))
+	narrowed := false
+	for i, colName := range fk.Columns {
+		if v, ok := values[colName]; ok && v != nil {
+			fixed[i] = v
+			narrowed = true
+		}
+	}
+	if row, ok := pool.pick(fixed); ok {
+		return row, nil
+	}
+	if narrowed && pool.sampled() && k.db != nil {
+		// the matching row may be out of the sample
+		return pickReferencedRow(k.db, k.dialect, fk, values)
+	}
+	return nil, sql.ErrNoRows
+}
+
+// poolName returns key of the pool of columns referenced by the foreign key
+func poolName(fk ForeignKey) string {
+	return fmt.Sprintf("%s (%s)", fk.RefQualifiedName(), strings.Join(fk.RefColumns, ", "))
+}
+
+// loadKeyPool reads values of referenced columns of all rows without NULLs
+func loadKeyPool(db Querier, dialect Dialect, fk ForeignKey, size int) (*keyPool, error) {
+	columns := quoteIdents(dialect, fk.RefColumns)
+	conditions := make([]string, 0, len(columns))
+	for _, colName := range columns {
+		conditions = append(conditions, colName+" IS NOT NULL")
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(columns, ", "),
+		quoteTable(dialect, fk.RefSchema, fk.RefTable), strings.Join(conditions, " AND "))
+
+	pool := newKeyPool(size)
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row := make([]interface{}, len(fk.RefColumns))
+		dest := make([]interface{}, len(row))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		pool.add(row)
+	}
+	return pool, rows.Err()
+}
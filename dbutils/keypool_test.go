@@ -0,0 +1,95 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestKeyPool_Reservoir(t *testing.T) {
+	pool := newKeyPool(10)
+	for i := 0; i < 1000; i++ {
+		pool.add([]interface{}{int64(i)})
+	}
+	assert.Len(t, pool.rows, 10)
+	assert.Equal(t, 1000, pool.seen)
+	assert.True(t, pool.sampled())
+
+	row, ok := pool.pick([]interface{}{nil})
+	assert.True(t, ok)
+	assert.Contains(t, pool.rows, row)
+}
+
+func TestKeyPool_PickFixed(t *testing.T) {
+	pool := newKeyPool(keyPoolSize)
+	pool.add([]interface{}{int64(1), "a"})
+	pool.add([]interface{}{int64(1), "b"})
+	pool.add([]interface{}{int64(2), "a"})
+
+	for i := 0; i < 20; i++ {
+		row, ok := pool.pick([]interface{}{"2", nil})
+		assert.True(t, ok)
+		assert.Equal(t, []interface{}{int64(2), "a"}, row)
+	}
+	_, ok := pool.pick([]interface{}{int64(3), nil})
+	assert.False(t, ok)
+	assert.False(t, pool.sampled())
+	// both picks narrow by the first column, rows are indexed once
+	assert.Len(t, pool.indexes, 1)
+}
+
+// fullKeyPool returns a pool of keyPoolSize rows of (id, id % 100)
+func fullKeyPool() *keyPool {
+	pool := newKeyPool(keyPoolSize)
+	for i := 0; i < keyPoolSize; i++ {
+		pool.add([]interface{}{int64(i), int64(i % 100)})
+	}
+	return pool
+}
+
+func TestKeyPool_PickIsNotLinear(t *testing.T) {
+	pool := fullKeyPool()
+	// scanning the pool on every pick takes seconds for this many picks
+	start := time.Now()
+	for i := 0; i < 20000; i++ {
+		_, ok := pool.pick([]interface{}{nil, nil})
+		assert.True(t, ok)
+		row, ok := pool.pick([]interface{}{nil, int64(i % 100)})
+		assert.True(t, ok)
+		assert.Equal(t, int64(i%100), row[1])
+	}
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func BenchmarkKeyPool_Pick(b *testing.B) {
+	pool := fullKeyPool()
+	b.Run("any", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pool.pick([]interface{}{nil, nil})
+		}
+	})
+	b.Run("narrowed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pool.pick([]interface{}{nil, int64(i % 100)})
+		}
+	})
+}
+
+func TestKeyPools_Pick(t *testing.T) {
+	db := openSQLiteTestDB(t)
+	_, err := db.Exec(`INSERT INTO customers (id, email) VALUES (1, 'a@example.com'), (2, 'b@example.com')`)
+	assert.NoError(t, err)
+
+	pools := newKeyPools(db, SQLiteDialect{})
+	fk := ForeignKey{Columns: []string{"customer_id"}, RefSchema: "main", RefTable: "customers", RefColumns: []string{"id"}}
+	row, err := pools.pick(fk, map[string]interface{}{"customer_id": int64(2)})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(2)}, row)
+	assert.Len(t, pools.pools, 1)
+
+	// the pool is loaded once, rows inserted later are not seen
+	_, err = db.Exec(`INSERT INTO customers (id, email) VALUES (3, 'c@example.com')`)
+	assert.NoError(t, err)
+	_, err = pools.pick(fk, map[string]interface{}{"customer_id": int64(3)})
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+package dbutils
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// LoadMethod - how generated rows are sent to the database
+type LoadMethod string
+
+const (
+	LoadInsert LoadMethod = "insert" // one INSERT per row
+	LoadBatch  LoadMethod = "batch"  // multi-row INSERT
+	LoadCopy   LoadMethod = "copy"   // COPY FROM STDIN, PostgreSQL only
+
+	copyChunkRows    = 10000 // rows sent by one COPY
+	defaultBatchRows = 1000  // rows of one multi-row INSERT if BatchSize is not set
+)
+
+// LoadOptions - how rows are inserted
+type LoadOptions struct {
+	Dialect   Dialect
+	Method    LoadMethod
+	BatchSize int // rows of one multi-row INSERT, 0 means 1000. Limited by the number of bind parameters.
+}
+
+// ParseLoadMethod parses --load-method and checks that the dialect supports it, empty name means insert
+func ParseLoadMethod(name string, dialect Dialect) (LoadMethod, error) {
+	switch method := LoadMethod(strings.ToLower(name)); method {
+	case "", LoadInsert:
+		return LoadInsert, nil
+	case LoadBatch:
+		return LoadBatch, nil
+	case LoadCopy:
+		if !dialect.Copy() {
+			return "", fmt.Errorf("load method copy is not supported by %s", dialect.Name())
+		}
+		return LoadCopy, nil
+	default:
+		return "", fmt.Errorf("unknown load method %s, expected copy, insert or batch", name)
+	}
+}
+
+// chunkRows returns number of rows loaded at once with the method, 1 means row by row
+func (o LoadOptions) chunkRows(columns int) int {
+	if columns == 0 {
+		// nothing to copy or batch, every row is inserted with column defaults, see insertStatement
+		return 1
+	}
+	switch o.Method {
+	case LoadCopy:
+		return copyChunkRows
+	case LoadBatch:
+		batchSize := o.BatchSize
+		if batchSize <= 0 {
+			batchSize = defaultBatchRows
+		}
+		return max(1, min(batchSize, o.Dialect.MaxParams()/columns))
+	default:
+		return 1
+	}
+}
+
+// RowsPerSecond returns loading speed
+func RowsPerSecond(rows int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(rows) / elapsed.Seconds()
+}
+
+// insertStatement returns INSERT of rows given as placeholder lists, e.g. ($1, $2).
+// Without columns it inserts one row of column defaults.
+func insertStatement(dialect Dialect, table Table, columns []string, rows []string) string {
+	if len(columns) == 0 {
+		return fmt.Sprintf("INSERT INTO %s %s", quoteTable(dialect, table.Schema, table.Name), dialect.DefaultRow())
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", quoteTable(dialect, table.Schema, table.Name),
+		strings.Join(quoteIdents(dialect, columns), ", "), strings.Join(rows, ", "))
+}
+
+// loadChunk inserts rows with the load method and returns the number of inserted rows.
+// If the chunk fails, its rows are inserted one by one, so only the bad rows are lost and reported.
+// Rows of the insert method are chunked only to share a savepoint, see Session.chunkRows.
+// In an atomic session a failed chunk fails the table.
+// first is number of the first row of the chunk in the table.
+func loadChunk(s *Session, opts LoadOptions, table Table, columns []string, rows [][]interface{}, first int) (int, error) {
+	err := s.write(func(q Querier) error {
+		switch opts.Method {
+		case LoadCopy:
+			return copyRows(q, table, columns, rows)
+		case LoadBatch:
+			return insertBatch(q, opts.Dialect, table, columns, rows)
+		default:
+			return insertEach(s, opts.Dialect, table, columns, rows)
+		}
+	})
+	if err == nil {
+		return len(rows), s.written(table, len(rows))
+	}
+	if s.Atomic() {
+		return 0, fmt.Errorf("rows %d-%d: %v", first, first+len(rows)-1, err)
+	}
+
+	inserted := 0
+	for i := range rows {
+		err := s.write(func(q Querier) error {
+			return insertBatch(q, opts.Dialect, table, columns, rows[i:i+1])
+		})
+		if err != nil {
+			fmt.Printf("Error inserting row %d into %s: %v\n", first+i, table.QualifiedName(), err)
+			continue
+		}
+		inserted++
+	}
+	return inserted, s.written(table, inserted)
+}
+
+// copyRows sends rows by COPY FROM STDIN in one transaction
+func copyRows(db Querier, table Table, columns []string, rows [][]interface{}) error {
+	statement := pq.CopyIn(table.Name, columns...)
+	if table.Schema != "" {
+		statement = pq.CopyInSchema(table.Schema, table.Name, columns...)
+	}
+	return inTransaction(db, func(tx Querier) error {
+		stmt, err := tx.Prepare(statement)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if _, err := stmt.Exec(row...); err != nil {
+				_ = stmt.Close()
+				return err
+			}
+		}
+		// flush buffered rows, errors of COPY are reported here
+		if _, err := stmt.Exec(); err != nil {
+			_ = stmt.Close()
+			return err
+		}
+		return stmt.Close()
+	})
+}
+
+// insertBatch inserts rows by one multi-row INSERT
+func insertBatch(db Querier, dialect Dialect, table Table, columns []string, rows [][]interface{}) error {
+	args := make([]interface{}, 0, len(rows)*len(columns))
+	values := make([]string, 0, len(rows))
+	for _, row := range rows {
+		placeholders := make([]string, 0, len(row))
+		for _, v := range row {
+			args = append(args, v)
+			placeholders = append(placeholders, dialect.Placeholder(len(args)))
+		}
+		values = append(values, "("+strings.Join(placeholders, ", ")+")")
+	}
+	_, err := db.Exec(insertStatement(dialect, table, columns, values), args...)
+	return err
+}
+
+// insertEach inserts rows by one single-row INSERT each, the statement is prepared once per transaction
+func insertEach(s *Session, dialect Dialect, table Table, columns []string, rows [][]interface{}) error {
+	placeholders := make([]string, len(columns))
+	for i := range placeholders {
+		placeholders[i] = dialect.Placeholder(i + 1)
+	}
+	stmt, err := s.prepare(insertStatement(dialect, table, columns, []string{"(" + strings.Join(placeholders, ", ") + ")"}))
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inTransaction runs fn in the transaction db, or in a new transaction if db is *sql.DB
+func inTransaction(db Querier, fn func(tx Querier) error) error {
+	beginner, ok := db.(interface{ Begin() (*sql.Tx, error) })
+	if !ok {
+		return fn(db)
+	}
+	tx, err := beginner.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,112 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseLoadMethod(t *testing.T) {
+	method, err := ParseLoadMethod("COPY", PostgresDialect{})
+	assert.NoError(t, err)
+	assert.Equal(t, LoadCopy, method)
+
+	method, err = ParseLoadMethod("", MySQLDialect{})
+	assert.NoError(t, err)
+	assert.Equal(t, LoadInsert, method)
+
+	_, err = ParseLoadMethod("copy", MySQLDialect{})
+	assert.ErrorContains(t, err, "not supported by mysql")
+	_, err = ParseLoadMethod("bulk", PostgresDialect{})
+	assert.ErrorContains(t, err, "unknown load method bulk")
+}
+
+func TestLoadOptions_ChunkRows(t *testing.T) {
+	assert.Equal(t, 1, LoadOptions{Dialect: PostgresDialect{}, Method: LoadInsert}.chunkRows(5))
+	assert.Equal(t, copyChunkRows, LoadOptions{Dialect: PostgresDialect{}, Method: LoadCopy}.chunkRows(5))
+	assert.Equal(t, 1, LoadOptions{Dialect: PostgresDialect{}, Method: LoadCopy}.chunkRows(0))
+	assert.Equal(t, defaultBatchRows, LoadOptions{Dialect: PostgresDialect{}, Method: LoadBatch}.chunkRows(5))
+	assert.Equal(t, 50, LoadOptions{Dialect: PostgresDialect{}, Method: LoadBatch, BatchSize: 50}.chunkRows(5))
+	// parameter limit: 65535 / 2 columns, 32766 / 100 columns of SQLite
+	assert.Equal(t, 32767, LoadOptions{Dialect: PostgresDialect{}, Method: LoadBatch, BatchSize: 50000}.chunkRows(2))
+	assert.Equal(t, 327, LoadOptions{Dialect: SQLiteDialect{}, Method: LoadBatch}.chunkRows(100))
+}
+
+func TestInsertRows_BatchFallback(t *testing.T) {
+	db := openSQLiteTestDB(t)
+	// generated values are even only by chance, so some rows of every batch fail
+	_, err := db.Exec(`CREATE TABLE numbers (n INTEGER NOT NULL CHECK (n % 2 = 0))`)
+	assert.NoError(t, err)
+	numbers := introspectSQLiteTable(t, db, "numbers")
+	numbers.RowNum = 50
+
+	session, err := NewSession(db, false, 0)
+	assert.NoError(t, err)
+	_, inserted, err := insertRows(session, LoadOptions{Dialect: SQLiteDialect{}, Method: LoadBatch, BatchSize: 10}, numbers)
+	assert.NoError(t, err)
+	assert.Greater(t, inserted, 0)
+	assert.Less(t, inserted, 50)
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT count(*) FROM numbers").Scan(&count))
+	assert.Equal(t, inserted, count)
+}
+
+func TestInsertRows_OnlyIdentity(t *testing.T) {
+	db := openSQLiteTestDB(t)
+	_, err := db.Exec(`CREATE TABLE tickets (id INTEGER PRIMARY KEY)`)
+	assert.NoError(t, err)
+	tickets := introspectSQLiteTable(t, db, "tickets")
+	tickets.RowNum = 5
+	assert.Empty(t, insertColumns(tickets))
+
+	for _, method := range []LoadMethod{LoadInsert, LoadBatch} {
+		session, err := NewSession(db, false, 2)
+		assert.NoError(t, err)
+		_, inserted, err := insertRows(session, LoadOptions{Dialect: SQLiteDialect{}, Method: method}, tickets)
+		assert.NoError(t, err, method)
+		assert.Equal(t, 5, inserted, method)
+		assert.NoError(t, session.Commit())
+	}
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT count(*) FROM tickets").Scan(&count))
+	assert.Equal(t, 10, count)
+}
+
+func TestInsertColumns_PrimaryKey(t *testing.T) {
+	// primary keys of a PostgreSQL schema: keys with a default are filled by the database,
+	// keys without one must be generated, or the INSERT violates their NOT NULL
+	path := filepath.Join(t.TempDir(), "schema.sql")
+	ddl := `
+CREATE TABLE orders (id serial PRIMARY KEY, note text);
+CREATE TABLE events (id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY, note text);
+CREATE TABLE sessions (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), note text);
+CREATE TABLE countries (code char(2) PRIMARY KEY, note text);
+CREATE TABLE order_lines (
+    order_id int REFERENCES orders (id),
+    line     int,
+    note     text,
+    PRIMARY KEY (order_id, line)
+);`
+	assert.NoError(t, os.WriteFile(path, []byte(ddl), 0644))
+	tables, _, err := ParseDDL(path, SchemaFilter{})
+	assert.NoError(t, err)
+
+	names := make(map[string][]string)
+	for _, table := range tables {
+		for _, col := range insertColumns(table) {
+			names[table.Name] = append(names[table.Name], col.Name)
+		}
+	}
+	assert.Equal(t, []string{"note"}, names["orders"])
+	assert.Equal(t, []string{"note"}, names["events"])
+	assert.Equal(t, []string{"note"}, names["sessions"])
+	assert.Equal(t, []string{"code", "note"}, names["countries"])
+	assert.Equal(t, []string{"line", "note", "order_id"}, names["order_lines"])
+}
+
+func TestRowsPerSecond(t *testing.T) {
+	assert.Equal(t, 500.0, RowsPerSecond(1000, 2*time.Second))
+	assert.Equal(t, 0.0, RowsPerSecond(1000, 0))
+}
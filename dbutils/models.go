@@ -1,20 +1,78 @@
 package dbutils
 
 type Table struct {
-	Name        string
-	Columns     map[string]Column
-	DependsOn   []string
-	PrimaryKeys map[string]bool
-	RowNum      int
-	Rules       map[string]func() string // key contains column name and value contains function to generate data
+	Schema         string
+	Name           string
+	Kind           string      // kind of relation: table, view, partitioned table, partition, ...
+	PartitionOf    string      // qualified name of the parent of a partition
+	PartitionBound string      // bound of a partition, e.g. FOR VALUES IN ('eu')
+	PartitionKey   string      // partition key of a partitioned table, e.g. RANGE (created_at)
+	Partitions     []Partition // partitions of a partitioned table
+	Columns        map[string]Column
+	DependsOn      []string // qualified names (schema.table) of referenced tables
+	PrimaryKeys    map[string]bool
+	ForeignKeys    []ForeignKey
+	Uniques        []UniqueConstraint
+	Checks         []CheckConstraint
+	Warnings       []string // problems found during introspection, e.g. CHECK constraints which can't be parsed
+	Tree           *Tree    // rows are generated as a hierarchy of a self-referencing foreign key, nil if not set
+	RowNum         int
+	Rules          map[string]func() string // key contains column name and value contains function to generate data
+}
+
+// QualifiedName returns table name prefixed with its schema
+func (t Table) QualifiedName() string {
+	return QualifyName(t.Schema, t.Name)
 }
 
 type Column struct {
-	Name         string
-	DataType     DataType
-	IsForeignKey bool
-	RefTable     string
-	DataGen      func() string
+	Name               string
+	DataType           DataType
+	IsNullable         bool
+	NullPercent        int    // probability in percents to insert NULL instead of generated value
+	Default            string // default expression, empty if column has no default
+	IsIdentity         bool
+	IdentityGeneration string // ALWAYS or BY DEFAULT for identity columns
+	IsGenerated        bool   // generated column (GENERATED ALWAYS AS (...) STORED), never written
+	UseDefault         bool   // column is left out of INSERT so the database fills it
+	IsForeignKey       bool
+	RefSchema          string
+	RefTable           string
+	Check              ColumnCheck              // restrictions parsed from CHECK constraints
+	TypeRange          ColumnCheck              // values allowed by the declared type, e.g. 0..1 of SQLite BOOLEAN
+	AttributeRules     map[string]func() string // generators of composite type attributes set by rules
+	DataGen            func() string
+}
+
+// ForeignKey - foreign key constraint, Columns[i] references RefColumns[i]
+type ForeignKey struct {
+	Name       string
+	Columns    []string
+	RefSchema  string
+	RefTable   string
+	RefColumns []string
+	Deferrable bool // constraint check can be deferred to the end of transaction
+	BackFill   bool // referenced table is inserted later (cycle), the key is filled by UPDATE after insertion
+}
+
+// RefQualifiedName returns referenced table name prefixed with its schema
+func (fk ForeignKey) RefQualifiedName() string {
+	return QualifyName(fk.RefSchema, fk.RefTable)
+}
+
+// SchemaFilter selects schemas to introspect.
+// Empty Include means every non-system schema.
+type SchemaFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// TableFilter selects tables to generate data for. Patterns are globs (audit_*) matched against
+// the table name, or against the qualified name if they contain a dot (billing.*).
+// Empty Include means every table.
+type TableFilter struct {
+	Include []string
+	Exclude []string
 }
 
 //type TableDependency struct {
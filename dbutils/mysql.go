@@ -0,0 +1,497 @@
+package dbutils
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/mo"
+)
+
+// Queries of the MySQL information_schema snapshot, each reads one kind of objects of the whole server
+const (
+	mysqlSystemSchemasCondition = `
+			TABLE_SCHEMA NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')`
+
+	getMySQLRelationsQuery = `
+		SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE
+		FROM information_schema.TABLES
+		WHERE TABLE_TYPE IN ('BASE TABLE', 'VIEW')
+			AND` + mysqlSystemSchemasCondition + `
+		ORDER BY TABLE_SCHEMA, TABLE_NAME
+	`
+
+	getMySQLColumnsQuery = `
+		SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COALESCE(COLUMN_DEFAULT, ''), EXTRA
+		FROM information_schema.COLUMNS
+		WHERE` + mysqlSystemSchemasCondition + `
+		ORDER BY TABLE_SCHEMA, TABLE_NAME, ORDINAL_POSITION
+	`
+
+	// primary and foreign keys
+	getMySQLKeysQuery = `
+		SELECT
+			TABLE_SCHEMA,
+			TABLE_NAME,
+			CONSTRAINT_NAME,
+			COLUMN_NAME,
+			COALESCE(REFERENCED_TABLE_SCHEMA, ''),
+			COALESCE(REFERENCED_TABLE_NAME, ''),
+			COALESCE(REFERENCED_COLUMN_NAME, '')
+		FROM information_schema.KEY_COLUMN_USAGE
+		WHERE (CONSTRAINT_NAME = 'PRIMARY' OR REFERENCED_TABLE_NAME IS NOT NULL)
+			AND` + mysqlSystemSchemasCondition + `
+		ORDER BY TABLE_SCHEMA, TABLE_NAME, CONSTRAINT_NAME, ORDINAL_POSITION
+	`
+
+	// CHECK_CONSTRAINTS exists since MySQL 8.0.16 and MariaDB 10.2
+	getMySQLHasChecksQuery = `
+		SELECT COUNT(*)
+		FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = 'information_schema'
+			AND TABLE_NAME = 'CHECK_CONSTRAINTS'
+	`
+
+	getMySQLCheckConstraintsQuery = `
+		SELECT tc.TABLE_SCHEMA, tc.TABLE_NAME, cc.CONSTRAINT_NAME, cc.CHECK_CLAUSE
+		FROM information_schema.TABLE_CONSTRAINTS tc
+		JOIN information_schema.CHECK_CONSTRAINTS cc
+			ON cc.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA
+			AND cc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+		WHERE tc.CONSTRAINT_TYPE = 'CHECK'
+			AND tc.` + mysqlSystemSchemasCondition + `
+		ORDER BY tc.TABLE_SCHEMA, tc.TABLE_NAME, cc.CONSTRAINT_NAME
+	`
+
+	// unique indexes on columns, including the primary key
+	getMySQLUniqueIndexesQuery = `
+		SELECT TABLE_SCHEMA, TABLE_NAME, INDEX_NAME, COLUMN_NAME
+		FROM information_schema.STATISTICS s
+		WHERE NON_UNIQUE = 0
+			AND NOT EXISTS (
+				SELECT 1
+				FROM information_schema.STATISTICS e
+				WHERE e.TABLE_SCHEMA = s.TABLE_SCHEMA
+					AND e.TABLE_NAME = s.TABLE_NAME
+					AND e.INDEX_NAME = s.INDEX_NAME
+					AND e.COLUMN_NAME IS NULL
+			)
+			AND` + mysqlSystemSchemasCondition + `
+		ORDER BY TABLE_SCHEMA, TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX
+	`
+)
+
+var (
+	mysqlTypePattern = regexp.MustCompile(`(?i)^([a-z]+)\s*(?:\((.*)\))?\s*((?:unsigned|signed|zerofill|\s)*)$`)
+	// character set introducers of string literals, e.g. _utf8mb4'paid'
+	mysqlIntroducerPattern = regexp.MustCompile(`\b_[a-z0-9]+'`)
+)
+
+// MySQLDialect - MySQL and MariaDB through go-sql-driver/mysql.
+// Databases of the server are schemas of tables.
+type MySQLDialect struct{}
+
+func (MySQLDialect) Name() string {
+	return "mysql"
+}
+
+func (MySQLDialect) DriverName() string {
+	return "mysql"
+}
+
+func (d MySQLDialect) DataSourceName(conn ConnConfig) (string, error) {
+	if err := requireServerParams(d, conn); err != nil {
+		return "", err
+	}
+	config := mysql.NewConfig()
+	config.User = conn.User
+	config.Passwd = conn.Password
+	config.Net = "tcp"
+	config.Addr = net.JoinHostPort(conn.Host, strconv.Itoa(conn.Port))
+	config.DBName = conn.DBName
+	return config.FormatDSN(), nil
+}
+
+func (MySQLDialect) DefaultPort() int {
+	return 3306
+}
+
+// Introspect reads tables of databases passing the filter, empty Include means the database of the connection
+func (MySQLDialect) Introspect(db *sql.DB, filter SchemaFilter) ([]Table, error) {
+	if len(filter.Include) == 0 {
+		var current sql.NullString
+		if err := db.QueryRow("SELECT DATABASE()").Scan(&current); err != nil {
+			return nil, err
+		}
+		if current.Valid {
+			filter.Include = []string{current.String}
+		}
+	}
+	s, err := loadMySQLCatalog(db)
+	if err != nil {
+		return nil, err
+	}
+	return s.tables(filter)
+}
+
+// ColumnType converts COLUMN_TYPE of information_schema.COLUMNS, e.g. int(10) unsigned or enum('a','b')
+func (MySQLDialect) ColumnType(columnType string) (DataType, ColumnCheck, error) {
+	columnType = strings.TrimSpace(columnType)
+	m := mysqlTypePattern.FindStringSubmatch(columnType)
+	if m == nil {
+		return nil, ColumnCheck{}, fmt.Errorf("invalid type %s", columnType)
+	}
+	name, args := strings.ToLower(m[1]), m[2]
+	unsigned := strings.Contains(strings.ToLower(m[3]), "unsigned")
+	var params []int
+	if args != "" && name != "enum" && name != "set" {
+		for _, arg := range strings.Split(args, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil {
+				return nil, ColumnCheck{}, fmt.Errorf("invalid type %s", columnType)
+			}
+			params = append(params, n)
+		}
+	}
+	param := func(i int) mo.Option[int] {
+		if i < len(params) {
+			return mo.Some(params[i])
+		}
+		return mo.None[int]()
+	}
+	var check ColumnCheck
+	if unsigned {
+		check.Min = mo.Some(0.0)
+	}
+	intRange := func(lo, hi, unsignedHi float64) ColumnCheck {
+		if unsigned {
+			return ColumnCheck{Min: mo.Some(0.0), Max: mo.Some(unsignedHi)}
+		}
+		return ColumnCheck{Min: mo.Some(lo), Max: mo.Some(hi)}
+	}
+
+	switch name {
+	case "tinyint":
+		if args == "1" && !unsigned {
+			// boolean
+			return Int{}, ColumnCheck{Min: mo.Some(0.0), Max: mo.Some(1.0)}, nil
+		}
+		return Int{}, intRange(-128, 127, 255), nil
+	case "bool", "boolean":
+		return Int{}, ColumnCheck{Min: mo.Some(0.0), Max: mo.Some(1.0)}, nil
+	case "smallint":
+		if unsigned {
+			return Int{}, ColumnCheck{Min: mo.Some(0.0), Max: mo.Some(65535.0)}, nil
+		}
+		return SmallInt{}, check, nil
+	case "mediumint":
+		return Int{}, intRange(-8388608, 8388607, 16777215), nil
+	case "int", "integer":
+		return Int{}, check, nil
+	case "bigint":
+		return BigInt{}, check, nil
+	case "decimal", "numeric", "dec", "fixed":
+		return Numeric{Precision: param(0), Scale: param(1)}, check, nil
+	case "float":
+		return Real{}, check, nil
+	case "double", "real":
+		return Float8{}, check, nil
+	case "char", "binary":
+		return Char{Len: param(0)}, check, nil
+	case "varchar", "varbinary":
+		return VarChar{MaxLen: param(0)}, check, nil
+	case "tinytext", "tinyblob":
+		return VarChar{MaxLen: mo.Some(255)}, check, nil
+	case "text", "mediumtext", "longtext", "blob", "mediumblob", "longblob":
+		return Text{}, check, nil
+	case "date":
+		return Date{}, check, nil
+	case "datetime", "timestamp":
+		return TimeStamp{Precision: param(0)}, check, nil
+	case "time":
+		return Time{Precision: param(0)}, check, nil
+	case "year":
+		return Int{}, ColumnCheck{Min: mo.Some(1901.0), Max: mo.Some(2155.0)}, nil
+	case "enum", "set":
+		labels := make([]string, 0)
+		for _, label := range splitTopLevel(args, ",") {
+			labels = append(labels, unquoteLiteral(label))
+		}
+		return Enum{Name: columnType, Labels: labels}, check, nil
+	case "json":
+		return JSON{}, check, nil
+	default:
+		return nil, ColumnCheck{}, fmt.Errorf("unsupported type %s", columnType)
+	}
+}
+
+func (MySQLDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (MySQLDialect) QuoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (MySQLDialect) RandomOrder() string {
+	return "RAND()"
+}
+
+func (MySQLDialect) Returning() bool {
+	return false
+}
+
+func (MySQLDialect) Copy() bool {
+	return false
+}
+
+func (MySQLDialect) MaxParams() int {
+	return 65535
+}
+
+// DefaultRow returns an empty column list, MySQL has no DEFAULT VALUES
+func (MySQLDialect) DefaultRow() string {
+	return "() VALUES ()"
+}
+
+// mysqlRelation - table or view from information_schema.TABLES
+type mysqlRelation struct {
+	schema string
+	name   string
+	kind   string
+}
+
+// mysqlColumn - column from information_schema.COLUMNS
+type mysqlColumn struct {
+	name       string
+	columnType string // type with modifiers, e.g. int(10) unsigned
+	nullable   string
+	dflt       string
+	extra      string // auto_increment, STORED GENERATED, ...
+}
+
+// mysqlKey - primary or foreign key from information_schema.KEY_COLUMN_USAGE
+type mysqlKey struct {
+	name       string
+	columns    []string
+	refSchema  string
+	refTable   string
+	refColumns []string
+}
+
+// mysqlCatalog - snapshot of information_schema, objects are grouped by qualified table name
+type mysqlCatalog struct {
+	relations []mysqlRelation
+	columns   map[string][]mysqlColumn
+	keys      map[string][]mysqlKey
+	checks    map[string][]CheckConstraint
+	indexes   map[string][]UniqueConstraint
+}
+
+func newMySQLCatalog() *mysqlCatalog {
+	return &mysqlCatalog{
+		columns: make(map[string][]mysqlColumn),
+		keys:    make(map[string][]mysqlKey),
+		checks:  make(map[string][]CheckConstraint),
+		indexes: make(map[string][]UniqueConstraint),
+	}
+}
+
+// loadMySQLCatalog reads the information_schema snapshot
+func loadMySQLCatalog(db *sql.DB) (*mysqlCatalog, error) {
+	s := newMySQLCatalog()
+	loaders := []struct {
+		name  string
+		query string
+		scan  func(rows *sql.Rows) error
+	}{
+		{"relations", getMySQLRelationsQuery, s.scanRelation},
+		{"columns", getMySQLColumnsQuery, s.scanColumn},
+		{"keys", getMySQLKeysQuery, s.scanKey},
+		{"unique indexes", getMySQLUniqueIndexesQuery, s.scanIndex},
+	}
+	for _, loader := range loaders {
+		if err := queryRows(db, loader.query, loader.scan); err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", loader.name, err)
+		}
+	}
+
+	var hasChecks int
+	err := db.QueryRow(getMySQLHasChecksQuery).Scan(&hasChecks)
+	if err == nil && hasChecks > 0 {
+		err = queryRows(db, getMySQLCheckConstraintsQuery, s.scanCheck)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading CHECK constraints: %v", err)
+	}
+	return s, nil
+}
+
+func (s *mysqlCatalog) scanRelation(rows *sql.Rows) error {
+	var r mysqlRelation
+	if err := rows.Scan(&r.schema, &r.name, &r.kind); err != nil {
+		return err
+	}
+	s.relations = append(s.relations, r)
+	return nil
+}
+
+func (s *mysqlCatalog) scanColumn(rows *sql.Rows) error {
+	var schema, table string
+	var c mysqlColumn
+	if err := rows.Scan(&schema, &table, &c.name, &c.columnType, &c.nullable, &c.dflt, &c.extra); err != nil {
+		return err
+	}
+	name := QualifyName(schema, table)
+	s.columns[name] = append(s.columns[name], c)
+	return nil
+}
+
+func (s *mysqlCatalog) scanKey(rows *sql.Rows) error {
+	var schema, table, col, refCol string
+	var k mysqlKey
+	if err := rows.Scan(&schema, &table, &k.name, &col, &k.refSchema, &k.refTable, &refCol); err != nil {
+		return err
+	}
+	s.addKey(QualifyName(schema, table), k, col, refCol)
+	return nil
+}
+
+// addKey adds a column of a key, rows are ordered by constraint, so columns of one constraint are adjacent
+func (s *mysqlCatalog) addKey(table string, k mysqlKey, col, refCol string) {
+	keys := s.keys[table]
+	if len(keys) == 0 || keys[len(keys)-1].name != k.name {
+		keys = append(keys, k)
+	}
+	last := &keys[len(keys)-1]
+	last.columns = append(last.columns, col)
+	if last.refTable != "" {
+		last.refColumns = append(last.refColumns, refCol)
+	}
+	s.keys[table] = keys
+}
+
+func (s *mysqlCatalog) scanCheck(rows *sql.Rows) error {
+	var schema, table string
+	var c CheckConstraint
+	if err := rows.Scan(&schema, &table, &c.Name, &c.Definition); err != nil {
+		return err
+	}
+	c.Definition = mysqlCheckDefinition(c.Definition)
+	name := QualifyName(schema, table)
+	s.checks[name] = append(s.checks[name], c)
+	return nil
+}
+
+func (s *mysqlCatalog) scanIndex(rows *sql.Rows) error {
+	var schema, table, index, col string
+	if err := rows.Scan(&schema, &table, &index, &col); err != nil {
+		return err
+	}
+	s.addIndexColumn(QualifyName(schema, table), index, col)
+	return nil
+}
+
+// addIndexColumn adds a column of a unique index, rows are ordered by index, so columns of one index are adjacent
+func (s *mysqlCatalog) addIndexColumn(table, index, col string) {
+	indexes := s.indexes[table]
+	if len(indexes) == 0 || indexes[len(indexes)-1].Name != index {
+		indexes = append(indexes, UniqueConstraint{Name: index})
+	}
+	last := &indexes[len(indexes)-1]
+	last.Columns = append(last.Columns, col)
+	s.indexes[table] = indexes
+}
+
+// mysqlCheckDefinition converts CHECK_CLAUSE to the PostgreSQL syntax understood by ParseCheckConstraint
+func mysqlCheckDefinition(clause string) string {
+	clause = strings.ReplaceAll(clause, "`", `"`)
+	clause = mysqlIntroducerPattern.ReplaceAllString(clause, "'")
+	return "CHECK (" + clause + ")"
+}
+
+// tables builds tables of databases passing the filter
+func (s *mysqlCatalog) tables(filter SchemaFilter) ([]Table, error) {
+	tables := make([]Table, 0)
+	for _, r := range s.relations {
+		if !filter.Match(r.schema) {
+			continue
+		}
+		table, err := s.table(r)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func (s *mysqlCatalog) table(r mysqlRelation) (Table, error) {
+	name := QualifyName(r.schema, r.name)
+	table := Table{
+		Schema:      r.schema,
+		Name:        r.name,
+		Kind:        KindTable,
+		Columns:     make(map[string]Column),
+		PrimaryKeys: make(map[string]bool),
+		ForeignKeys: make([]ForeignKey, 0),
+		Uniques:     s.indexes[name],
+		Checks:      s.checks[name],
+		RowNum:      0,
+		Rules:       make(map[string]func() string),
+	}
+	if r.kind == "VIEW" {
+		table.Kind = KindView
+	}
+	if table.Uniques == nil {
+		table.Uniques = make([]UniqueConstraint, 0)
+	}
+	if table.Checks == nil {
+		table.Checks = make([]CheckConstraint, 0)
+	}
+
+	for _, c := range s.columns[name] {
+		dataType, check, err := MySQLDialect{}.ColumnType(c.columnType)
+		if err != nil {
+			return Table{}, fmt.Errorf("column %s.%s: %v", name, c.name, err)
+		}
+		extra := strings.ToUpper(c.extra)
+		col := Column{
+			Name:       c.name,
+			DataType:   dataType,
+			IsNullable: c.nullable == "YES",
+			Default:    c.dflt,
+			IsIdentity: strings.Contains(extra, "AUTO_INCREMENT"),
+			// VIRTUAL GENERATED, STORED GENERATED, but not DEFAULT_GENERATED
+			IsGenerated: strings.Contains(extra, " GENERATED"),
+			Check:       check,
+			TypeRange:   check,
+		}
+		if col.IsIdentity {
+			col.IdentityGeneration = "BY DEFAULT"
+		}
+		table.Columns[c.name] = col
+	}
+
+	for _, k := range s.keys[name] {
+		if k.refTable == "" {
+			for _, colName := range k.columns {
+				table.PrimaryKeys[colName] = true
+			}
+			continue
+		}
+		table.ForeignKeys = append(table.ForeignKeys, ForeignKey{
+			Name:       k.name,
+			Columns:    k.columns,
+			RefSchema:  k.refSchema,
+			RefTable:   k.refTable,
+			RefColumns: k.refColumns,
+		})
+	}
+	completeTable(&table)
+	return table, nil
+}
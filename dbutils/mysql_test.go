@@ -0,0 +1,97 @@
+package dbutils
+
+import (
+	"github.com/samber/mo"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMySQLDialect_ColumnType(t *testing.T) {
+	cases := []struct {
+		columnType string
+		dataType   DataType
+		check      ColumnCheck
+	}{
+		{"int", Int{}, ColumnCheck{}},
+		{"int(10) unsigned", Int{}, ColumnCheck{Min: mo.Some(0.0)}},
+		{"tinyint(1)", Int{}, ColumnCheck{Min: mo.Some(0.0), Max: mo.Some(1.0)}},
+		{"tinyint(4)", Int{}, ColumnCheck{Min: mo.Some(-128.0), Max: mo.Some(127.0)}},
+		{"tinyint unsigned", Int{}, ColumnCheck{Min: mo.Some(0.0), Max: mo.Some(255.0)}},
+		{"smallint", SmallInt{}, ColumnCheck{}},
+		{"bigint(20) unsigned zerofill", BigInt{}, ColumnCheck{Min: mo.Some(0.0)}},
+		{"decimal(10,2)", Numeric{Precision: mo.Some(10), Scale: mo.Some(2)}, ColumnCheck{}},
+		{"double", Float8{}, ColumnCheck{}},
+		{"varchar(50)", VarChar{MaxLen: mo.Some(50)}, ColumnCheck{}},
+		{"char(2)", Char{Len: mo.Some(2)}, ColumnCheck{}},
+		{"tinytext", VarChar{MaxLen: mo.Some(255)}, ColumnCheck{}},
+		{"longtext", Text{}, ColumnCheck{}},
+		{"datetime(6)", TimeStamp{Precision: mo.Some(6)}, ColumnCheck{}},
+		{"year", Int{}, ColumnCheck{Min: mo.Some(1901.0), Max: mo.Some(2155.0)}},
+		{"enum('New','it''s')", Enum{Name: "enum('New','it''s')", Labels: []string{"New", "it's"}}, ColumnCheck{}},
+		{"json", JSON{}, ColumnCheck{}},
+	}
+	for _, c := range cases {
+		dataType, check, err := MySQLDialect{}.ColumnType(c.columnType)
+		assert.NoError(t, err, c.columnType)
+		assert.Equal(t, c.dataType, dataType, c.columnType)
+		assert.Equal(t, c.check, check, c.columnType)
+	}
+
+	_, _, err := MySQLDialect{}.ColumnType("geometry")
+	assert.ErrorContains(t, err, "unsupported type geometry")
+}
+
+func TestMySQLCatalog_Tables(t *testing.T) {
+	catalog := newMySQLCatalog()
+	catalog.relations = []mysqlRelation{
+		{schema: "shop", name: "customers", kind: "BASE TABLE"},
+		{schema: "shop", name: "orders", kind: "BASE TABLE"},
+		{schema: "shop", name: "order_totals", kind: "VIEW"},
+		{schema: "other", name: "log", kind: "BASE TABLE"},
+	}
+	catalog.columns["shop.customers"] = []mysqlColumn{
+		{name: "id", columnType: "int unsigned", nullable: "NO", extra: "auto_increment"},
+	}
+	catalog.columns["shop.orders"] = []mysqlColumn{
+		{name: "id", columnType: "bigint", nullable: "NO", extra: "auto_increment"},
+		{name: "customer_id", columnType: "int unsigned", nullable: "YES"},
+		{name: "status", columnType: "enum('new','paid')", nullable: "NO", dflt: "new"},
+		{name: "quantity", columnType: "int", nullable: "NO"},
+		{name: "total", columnType: "decimal(10,2)", nullable: "YES", extra: "STORED GENERATED"},
+		{name: "created_at", columnType: "timestamp", nullable: "NO", dflt: "CURRENT_TIMESTAMP",
+			extra: "DEFAULT_GENERATED"},
+	}
+	catalog.addKey("shop.orders", mysqlKey{name: "PRIMARY"}, "id", "")
+	catalog.addKey("shop.orders", mysqlKey{name: "orders_customer_fk", refSchema: "shop", refTable: "customers"},
+		"customer_id", "id")
+	catalog.addIndexColumn("shop.orders", "PRIMARY", "id")
+	catalog.checks["shop.orders"] = []CheckConstraint{
+		{Name: "orders_chk_1", Definition: mysqlCheckDefinition("(`quantity` > 0)")},
+		{Name: "orders_chk_2", Definition: mysqlCheckDefinition("(`status` in (_utf8mb4'new',_utf8mb4'paid'))")},
+	}
+
+	tables, err := catalog.tables(SchemaFilter{Include: []string{"shop"}})
+	assert.NoError(t, err)
+	assert.Len(t, tables, 3)
+
+	orders := tables[1]
+	assert.Equal(t, "shop.orders", orders.QualifiedName())
+	assert.Equal(t, KindView, tables[2].Kind)
+	assert.Equal(t, map[string]bool{"id": true}, orders.PrimaryKeys)
+	assert.Equal(t, []string{"shop.customers"}, orders.DependsOn)
+	assert.True(t, orders.Columns["customer_id"].IsForeignKey)
+	assert.True(t, orders.Columns["id"].IsIdentity)
+	assert.True(t, orders.Columns["total"].IsGenerated)
+	assert.False(t, orders.Columns["created_at"].IsGenerated)
+	assert.Equal(t, 0.0, orders.Columns["quantity"].Check.Min.OrElse(-1))
+	assert.True(t, orders.Columns["quantity"].Check.MinStrict)
+	assert.Equal(t, []string{"new", "paid"}, orders.Columns["status"].Check.OneOf)
+	assert.Empty(t, orders.Warnings)
+	assert.Len(t, orders.Uniques, 1)
+}
+
+func TestMySQLDialect_DataSourceName(t *testing.T) {
+	dsn, err := MySQLDialect{}.DataSourceName(ConnConfig{Host: "db", Port: 3306, User: "root", Password: "secret", DBName: "shop"})
+	assert.NoError(t, err)
+	assert.Equal(t, "root:secret@tcp(db:3306)/shop", dsn)
+}
@@ -0,0 +1,220 @@
+package dbutils
+
+import (
+	"sync"
+)
+
+// minPartRows - a table is split between workers only into parts of at least this many rows
+const minPartRows = 10000
+
+// tablePart - rows first..first+rows-1 of a table, loaded by one worker
+type tablePart struct {
+	table   Table
+	first   int
+	rows    int
+	tracker *uniqueTracker // shared by parts of a split table, nil if the part is the whole table
+}
+
+// GroupResult - outcome of loading a group of tables returned by InsertGroups
+type GroupResult struct {
+	Group    []Table
+	Inserted int
+	Err      error
+}
+
+// splitTable divides rows of a table between at most workers parts of at least minPartRows rows.
+// Tables whose rows depend on each other (trees, foreign keys filled after insert) are not split.
+func splitTable(table Table, workers int) []tablePart {
+	parts := min(workers, table.RowNum/minPartRows)
+	if parts < 2 || table.Tree != nil || hasBackFill(table) {
+		return []tablePart{{table: table, rows: table.RowNum}}
+	}
+	split := make([]tablePart, parts)
+	first := 0
+	for i := range split {
+		rows := table.RowNum / parts
+		if i < table.RowNum%parts {
+			rows++
+		}
+		split[i] = tablePart{table: table, first: first, rows: rows}
+		first += rows
+	}
+	return split
+}
+
+// groupParents returns indexes of groups each group references, groups are returned by InsertGroups
+func groupParents(groups [][]Table) [][]int {
+	groupOf := make(map[string]int)
+	for i, group := range groups {
+		for _, table := range group {
+			groupOf[table.QualifiedName()] = i
+		}
+	}
+	parents := make([][]int, len(groups))
+	for i, group := range groups {
+		seen := make(map[int]bool)
+		for _, table := range group {
+			for _, name := range table.DependsOn {
+				// tables which are not loaded are expected to have rows already
+				if j, ok := groupOf[name]; ok && j != i && !seen[j] {
+					seen[j] = true
+					parents[i] = append(parents[i], j)
+				}
+			}
+		}
+	}
+	return parents
+}
+
+// partJob - a group, or a part of its only table, loaded by a worker
+type partJob struct {
+	group   int
+	part    *tablePart     // nil loads the whole group
+	tracker *sharedTracker // unique tracker of the split table
+}
+
+type jobResult struct {
+	group    int
+	inserted int
+	err      error
+}
+
+// sharedTracker - unique tracker of a split table, loaded by the worker which starts first
+type sharedTracker struct {
+	once    sync.Once
+	tracker *uniqueTracker
+	err     error
+}
+
+func (t *sharedTracker) get(s *Session, dialect Dialect, table Table) (*uniqueTracker, error) {
+	t.once.Do(func() {
+		t.tracker, t.err = newUniqueTracker(s, dialect, table, insertColumns(table))
+	})
+	return t.tracker, t.err
+}
+
+// groupJobs returns jobs loading group i, a group of one large table is split between workers
+func groupJobs(group []Table, i int, workers int) []partJob {
+	parts := splitTable(group[0], workers)
+	if len(group) > 1 || len(parts) == 1 {
+		return []partJob{{group: i}}
+	}
+	tracker := &sharedTracker{}
+	jobs := make([]partJob, len(parts))
+	for j := range parts {
+		jobs[j] = partJob{group: i, part: &parts[j], tracker: tracker}
+	}
+	return jobs
+}
+
+func runJob(s *Session, opts LoadOptions, groups [][]Table, job partJob) jobResult {
+	if job.part == nil {
+		inserted, err := GenerateAndInsertGroup(s, opts, groups[job.group])
+		return jobResult{group: job.group, inserted: inserted, err: err}
+	}
+	part := *job.part
+	tracker, err := job.tracker.get(s, opts.Dialect, part.table)
+	if err != nil {
+		return jobResult{group: job.group, err: err}
+	}
+	part.tracker = tracker
+	_, inserted, err := insertPart(s, opts, part)
+	return jobResult{group: job.group, inserted: inserted, err: err}
+}
+
+// LoadGroups loads groups returned by InsertGroups, every session is a worker loading one group
+// or table part at a time. A group starts when the groups it references are done, so independent
+// groups load at the same time. A large table is split into parts loaded by several workers.
+// If a group of an atomic session fails, no more jobs are started.
+// Results are returned in order of groups, groups which were not started are left out.
+func LoadGroups(sessions []*Session, opts LoadOptions, groups [][]Table) []GroupResult {
+	parents := groupParents(groups)
+	children := make([][]int, len(groups))
+	waiting := make([]int, len(groups)) // parents not done yet
+	for i, groupParents := range parents {
+		waiting[i] = len(groupParents)
+		for _, j := range groupParents {
+			children[j] = append(children[j], i)
+		}
+	}
+
+	jobs := make(chan partJob)
+	done := make(chan jobResult)
+	var wg sync.WaitGroup
+	for _, s := range sessions {
+		wg.Add(1)
+		go func(s *Session) {
+			defer wg.Done()
+			for job := range jobs {
+				result := runJob(s, opts, groups, job)
+				// children of the group may be loaded by other sessions, they must see its rows
+				if err := s.flush(); err != nil && result.err == nil {
+					result.err = err
+				}
+				done <- result
+			}
+		}(s)
+	}
+
+	results := make([]*GroupResult, len(groups))
+	remaining := make([]int, len(groups)) // jobs of the group not done yet
+	queue := make([]partJob, 0)
+	enqueue := func(i int) {
+		groupJobs := groupJobs(groups[i], i, len(sessions))
+		remaining[i] = len(groupJobs)
+		queue = append(queue, groupJobs...)
+	}
+	for i := range groups {
+		if waiting[i] == 0 {
+			enqueue(i)
+		}
+	}
+
+	stopped := false
+	running := 0
+	for running > 0 || (len(queue) > 0 && !stopped) {
+		var send chan partJob
+		var next partJob
+		if len(queue) > 0 && !stopped {
+			// a nil channel never receives, then only results are awaited
+			send = jobs
+			next = queue[0]
+		}
+		select {
+		case send <- next:
+			queue = queue[1:]
+			running++
+			if results[next.group] == nil {
+				results[next.group] = &GroupResult{Group: groups[next.group]}
+			}
+		case r := <-done:
+			running--
+			result := results[r.group]
+			result.Inserted += r.inserted
+			if r.err != nil && result.Err == nil {
+				result.Err = r.err
+			}
+			if r.err != nil && sessions[0].Atomic() {
+				stopped = true
+			}
+			if remaining[r.group]--; remaining[r.group] > 0 {
+				continue
+			}
+			for _, child := range children[r.group] {
+				if waiting[child]--; waiting[child] == 0 {
+					enqueue(child)
+				}
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	loaded := make([]GroupResult, 0, len(groups))
+	for _, result := range results {
+		if result != nil {
+			loaded = append(loaded, *result)
+		}
+	}
+	return loaded
+}
@@ -0,0 +1,104 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSplitTable(t *testing.T) {
+	parts := splitTable(Table{Name: "events", RowNum: 25001}, 4)
+	assert.Len(t, parts, 2)
+	assert.Equal(t, 0, parts[0].first)
+	assert.Equal(t, 12501, parts[0].rows)
+	assert.Equal(t, 12501, parts[1].first)
+	assert.Equal(t, 12500, parts[1].rows)
+
+	assert.Len(t, splitTable(Table{Name: "events", RowNum: 15000}, 4), 1)
+	assert.Len(t, splitTable(Table{Name: "events", RowNum: 100000}, 1), 1)
+	assert.Len(t, splitTable(Table{Name: "categories", RowNum: 100000, Tree: &Tree{}}, 4), 1)
+}
+
+func TestGroupParents(t *testing.T) {
+	groups := [][]Table{
+		{{Schema: "public", Name: "users"}},
+		{{Schema: "public", Name: "products", DependsOn: []string{"public.vendors"}}},
+		{{Schema: "public", Name: "orders", DependsOn: []string{"public.users", "public.products"}}},
+		{cycleTable("a", "b", true, false), cycleTable("b", "a", true, false)},
+		{{Schema: "public", Name: "order_items", DependsOn: []string{"public.orders", "public.a", "public.b"}}},
+	}
+	// vendors is not loaded, a and b are one group
+	assert.Equal(t, [][]int{nil, nil, {0, 1}, nil, {2, 3}}, groupParents(groups))
+}
+
+func TestLoadGroups(t *testing.T) {
+	db := openSQLiteTestDB(t)
+	tables, err := SQLiteDialect{}.Introspect(db, SchemaFilter{})
+	assert.NoError(t, err)
+	targets := make([]Table, 0)
+	for _, table := range TopologicalSort(tables) {
+		if table.Insertable() {
+			// customers is split between workers, parts share values of the unique email
+			table.RowNum = 50
+			if table.Name == "customers" {
+				table.RowNum = 2 * minPartRows
+			}
+			targets = append(targets, table)
+		}
+	}
+
+	sessions := make([]*Session, 0)
+	for i := 0; i < 3; i++ {
+		session, err := NewSession(db, false, 0)
+		assert.NoError(t, err)
+		sessions = append(sessions, session)
+	}
+	results := LoadGroups(sessions, LoadOptions{Dialect: SQLiteDialect{}, Method: LoadBatch}, InsertGroups(targets))
+	assert.Len(t, results, 3)
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+		assert.Equal(t, result.Group[0].RowNum, result.Inserted, result.Group[0].Name)
+	}
+
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT count(DISTINCT email) FROM customers").Scan(&count))
+	assert.Equal(t, 2*minPartRows, count)
+	assert.NoError(t, db.QueryRow("SELECT count(*) FROM pragma_foreign_key_check").Scan(&count))
+	assert.Zero(t, count)
+}
+
+func TestLoadGroups_CommitEvery(t *testing.T) {
+	db := openSQLiteTestDB(t)
+	tables, err := SQLiteDialect{}.Introspect(db, SchemaFilter{})
+	assert.NoError(t, err)
+	targets := make([]Table, 0)
+	for _, table := range TopologicalSort(tables) {
+		if table.Insertable() {
+			table.RowNum = 50
+			targets = append(targets, table)
+		}
+	}
+
+	// rows of a table are fewer than commitEvery, they are committed when the table is done
+	sessions := make([]*Session, 0)
+	for i := 0; i < 2; i++ {
+		session, err := NewSession(db, false, 1000)
+		assert.NoError(t, err)
+		sessions = append(sessions, session)
+	}
+	results := LoadGroups(sessions, LoadOptions{Dialect: SQLiteDialect{}, Method: LoadInsert}, InsertGroups(targets))
+	assert.Len(t, results, 3)
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+		assert.Equal(t, 50, result.Inserted, result.Group[0].Name)
+	}
+	for _, session := range sessions {
+		assert.NoError(t, session.Commit())
+	}
+	for _, table := range targets {
+		committed := 0
+		for _, session := range sessions {
+			committed += session.Committed(table)
+		}
+		assert.Equal(t, 50, committed, table.Name)
+	}
+}
@@ -0,0 +1,261 @@
+package dbutils
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kinds of relations
+const (
+	KindTable            = "table"
+	KindView             = "view"
+	KindMaterializedView = "materialized view"
+	KindForeignTable     = "foreign table"
+	KindPartitionedTable = "partitioned table"
+	KindPartition        = "partition"
+)
+
+// Partition - partition of a partitioned table
+type Partition struct {
+	Name  string // qualified name
+	Bound string // partition bound, e.g. FOR VALUES FROM ('2024-01-01') TO ('2024-02-01')
+}
+
+var (
+	partitionKeyPattern = regexp.MustCompile(`(?i)^(RANGE|LIST|HASH)\s*\(\s*("(?:[^"]|"")+"|[A-Za-z_]\w*)\s*\)$`)
+	rangeBoundPattern   = regexp.MustCompile(`(?i)^FOR VALUES FROM \((.+)\) TO \((.+)\)$`)
+	listBoundPattern    = regexp.MustCompile(`(?i)^FOR VALUES IN \((.+)\)$`)
+)
+
+// timestamp layouts of partition bounds
+var boundTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// relationKind converts pg_class.relkind to a kind of relation
+func relationKind(relkind string, isPartition bool) string {
+	if isPartition {
+		return KindPartition
+	}
+	switch relkind {
+	case "v":
+		return KindView
+	case "m":
+		return KindMaterializedView
+	case "f":
+		return KindForeignTable
+	case "p":
+		return KindPartitionedTable
+	default:
+		return KindTable
+	}
+}
+
+// Insertable reports whether rows are inserted into the relation.
+// Views, foreign tables and partitions are left out, rows of partitions are inserted through their parent.
+// Empty kind means an ordinary table.
+func (t Table) Insertable() bool {
+	switch t.Kind {
+	case "", KindTable, KindPartitionedTable:
+		return true
+	}
+	return false
+}
+
+// linkPartitions adds partitions to their parent tables
+func linkPartitions(tables []Table) {
+	parents := make(map[string]int)
+	for i, table := range tables {
+		if table.Kind == KindPartitionedTable {
+			parents[table.QualifiedName()] = i
+		}
+	}
+	for _, table := range tables {
+		if i, ok := parents[table.PartitionOf]; ok && table.Kind == KindPartition {
+			tables[i].Partitions = append(tables[i].Partitions, Partition{Name: table.QualifiedName(), Bound: table.PartitionBound})
+		}
+	}
+}
+
+// findPartition looks up partition by qualified or bare name
+func (t Table) findPartition(name string) (Partition, bool) {
+	for _, p := range t.Partitions {
+		if p.Name == name || p.Name == QualifyName(t.Schema, name) {
+			return p, true
+		}
+	}
+	return Partition{}, false
+}
+
+// partitionKeyGenerator returns name of the partition key column and its generator producing
+// only values routed to the given partitions. Single column RANGE and LIST keys are supported.
+func partitionKeyGenerator(table Table, names []string) (string, func() string, error) {
+	if table.Kind != KindPartitionedTable {
+		return "", nil, fmt.Errorf("table %s is not partitioned", table.QualifiedName())
+	}
+	m := partitionKeyPattern.FindStringSubmatch(table.PartitionKey)
+	if m == nil || strings.EqualFold(m[1], "HASH") {
+		return "", nil, fmt.Errorf("partition key %s of %s is not supported, only single column RANGE and LIST keys are",
+			table.PartitionKey, table.QualifiedName())
+	}
+	colName := unquoteIdent(m[2])
+	col, present := table.Columns[colName]
+	if !present {
+		return "", nil, fmt.Errorf("partition key column %s of %s not found", colName, table.QualifiedName())
+	}
+
+	gens := make([]func() string, 0, len(names))
+	for _, name := range names {
+		partition, ok := table.findPartition(name)
+		if !ok {
+			return "", nil, fmt.Errorf("table %s has no partition %s", table.QualifiedName(), name)
+		}
+		gen, err := boundGenerator(col.DataType, partition.Bound)
+		if err != nil {
+			return "", nil, fmt.Errorf("partition %s: %v", partition.Name, err)
+		}
+		gens = append(gens, gen)
+	}
+	return colName, func() string {
+		return gens[rand.Intn(len(gens))]()
+	}, nil
+}
+
+// boundGenerator returns generator of values inside partition bound
+func boundGenerator(dataType DataType, bound string) (func() string, error) {
+	bound = strings.TrimSpace(whitespacePattern.ReplaceAllString(bound, " "))
+	if m := listBoundPattern.FindStringSubmatch(bound); m != nil {
+		values := make([]string, 0)
+		for _, item := range splitTopLevel(m[1], ",") {
+			if !strings.EqualFold(item, "NULL") {
+				values = append(values, unquoteLiteral(item))
+			}
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("bound %s has no values", bound)
+		}
+		return func() string {
+			return values[rand.Intn(len(values))]
+		}, nil
+	}
+	m := rangeBoundPattern.FindStringSubmatch(bound)
+	if m == nil {
+		return nil, fmt.Errorf("bound %s is not supported, only FOR VALUES FROM ... TO and FOR VALUES IN are", bound)
+	}
+	from, to := strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+	if strings.Contains(from, ",") || strings.Contains(to, ",") {
+		return nil, fmt.Errorf("bound %s of several columns is not supported", bound)
+	}
+
+	if domain, ok := dataType.(Domain); ok {
+		dataType = domain.Base
+	}
+	switch t := dataType.(type) {
+	case Int, BigInt, SmallInt:
+		lo, hi, err := rangeBounds(from, to, 1000, func(s string) (int64, error) {
+			return strconv.ParseInt(s, 10, 64)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func() string {
+			return strconv.FormatInt(lo+rand.Int63n(hi-lo), 10)
+		}, nil
+	case Numeric, Float8, Real:
+		lo, hi, err := rangeBounds(from, to, 1000, func(s string) (float64, error) {
+			return strconv.ParseFloat(s, 64)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if numeric, ok := t.(Numeric); ok {
+			return func() string {
+				return numericBelow(numeric, lo+(hi-lo)*rand.Float64(), lo, hi)
+			}, nil
+		}
+		return func() string {
+			return strconv.FormatFloat(lo+(hi-lo)*rand.Float64(), 'f', -1, 64)
+		}, nil
+	case Date, TimeStamp:
+		day := int64(24 * time.Hour)
+		lo, hi, err := rangeBounds(from, to, 365*day, func(s string) (int64, error) {
+			parsed, err := parseBoundTime(s)
+			return parsed.UnixNano(), err
+		})
+		if err != nil {
+			return nil, err
+		}
+		_, isDate := t.(Date)
+		return func() string {
+			if isDate {
+				// whole days in [lo, hi)
+				days := (hi - lo + day - 1) / day
+				return time.Unix(0, lo+rand.Int63n(days)*day).UTC().Format("2006-01-02")
+			}
+			return time.Unix(0, lo+rand.Int63n(hi-lo)).UTC().Format("2006-01-02 15:04:05.999999Z07:00")
+		}, nil
+	default:
+		return nil, fmt.Errorf("range partitions of type %T are not supported", dataType)
+	}
+}
+
+// numericBelow formats v with the scale of the column, so the database stores it as is.
+// Rounding can't reach hi: the value is the largest one below hi then, but not less than lo.
+func numericBelow(n Numeric, v, lo, hi float64) string {
+	formatted := n.format(v)
+	if f, err := strconv.ParseFloat(formatted, 64); err == nil && f >= hi {
+		digits := n.digits()
+		formatted = strconv.FormatFloat(math.Max(lo, hi-math.Pow10(-digits)), 'f', digits, 64)
+	}
+	return formatted
+}
+
+// rangeBounds parses range bounds, MINVALUE and MAXVALUE are replaced by span from the other bound
+func rangeBounds[T int64 | float64](from, to string, span T, parse func(string) (T, error)) (T, T, error) {
+	isMin := strings.EqualFold(from, "MINVALUE")
+	isMax := strings.EqualFold(to, "MAXVALUE")
+	if isMin && isMax {
+		return 0, 0, fmt.Errorf("range from MINVALUE to MAXVALUE is not supported")
+	}
+	var lo, hi T
+	var err error
+	if !isMin {
+		if lo, err = parse(unquoteLiteral(from)); err != nil {
+			return 0, 0, fmt.Errorf("invalid bound %s: %v", from, err)
+		}
+	}
+	if !isMax {
+		if hi, err = parse(unquoteLiteral(to)); err != nil {
+			return 0, 0, fmt.Errorf("invalid bound %s: %v", to, err)
+		}
+	}
+	if isMin {
+		lo = hi - span
+	}
+	if isMax {
+		hi = lo + span
+	}
+	if lo >= hi {
+		return 0, 0, fmt.Errorf("empty range from %s to %s", from, to)
+	}
+	return lo, hi, nil
+}
+
+func parseBoundTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range boundTimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
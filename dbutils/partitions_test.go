@@ -0,0 +1,99 @@
+package dbutils
+
+import (
+	"github.com/samber/mo"
+	"github.com/stretchr/testify/assert"
+	"github.com/victornguen/db-faker/datagen"
+	"strconv"
+	"testing"
+)
+
+func TestRelationKind(t *testing.T) {
+	assert.Equal(t, KindTable, relationKind("r", false))
+	assert.Equal(t, KindView, relationKind("v", false))
+	assert.Equal(t, KindMaterializedView, relationKind("m", false))
+	assert.Equal(t, KindForeignTable, relationKind("f", false))
+	assert.Equal(t, KindPartitionedTable, relationKind("p", false))
+	// sub-partitioned partition is still a partition
+	assert.Equal(t, KindPartition, relationKind("p", true))
+
+	assert.True(t, Table{Kind: KindPartitionedTable}.Insertable())
+	assert.False(t, Table{Kind: KindView}.Insertable())
+	assert.False(t, Table{Kind: KindPartition}.Insertable())
+}
+
+func TestBoundGenerator(t *testing.T) {
+	gen, err := boundGenerator(Int{}, "FOR VALUES FROM (100) TO (200)")
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		n, err := strconv.Atoi(gen())
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, n, 100)
+		assert.Less(t, n, 200)
+	}
+
+	// truncated to the scale, -1.004 would become the excluded bound -1.00
+	numeric := Numeric{Precision: mo.Some(5), Scale: mo.Some(2)}
+	gen, err = boundGenerator(numeric, "FOR VALUES FROM ('-1.01') TO ('-1.00')")
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, "-1.01", gen())
+	}
+	gen, err = boundGenerator(numeric, "FOR VALUES FROM (0) TO (10)")
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		v := gen()
+		assert.Regexp(t, `^\d\.\d\d$`, v)
+	}
+	assert.Equal(t, "0.99", numericBelow(numeric, 0.999, 0, 1))
+	assert.Equal(t, "7", numericBelow(Numeric{Precision: mo.Some(3)}, 7.5, 7, 8))
+
+	gen, err = boundGenerator(Date{}, "FOR VALUES FROM ('2024-01-01') TO ('2024-02-01')")
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		v := gen()
+		assert.GreaterOrEqual(t, v, "2024-01-01")
+		assert.LessOrEqual(t, v, "2024-01-31")
+	}
+
+	gen, err = boundGenerator(TimeStamp{}, "FOR VALUES FROM (MINVALUE) TO ('2024-01-01 00:00:00')")
+	assert.NoError(t, err)
+	assert.Less(t, gen(), "2024-01-01")
+
+	gen, err = boundGenerator(Text{}, "FOR VALUES IN ('eu', 'us', NULL)")
+	assert.NoError(t, err)
+	assert.Contains(t, []string{"eu", "us"}, gen())
+
+	_, err = boundGenerator(Int{}, "DEFAULT")
+	assert.Error(t, err)
+}
+
+func TestApplyRulesToTables_Partitions(t *testing.T) {
+	tables := []Table{
+		{
+			Schema:       "public",
+			Name:         "events",
+			Kind:         KindPartitionedTable,
+			PartitionKey: "LIST (region)",
+			Columns:      map[string]Column{"region": {Name: "region", DataType: Text{}}},
+		},
+		{Schema: "public", Name: "events_eu", Kind: KindPartition, PartitionOf: "public.events", PartitionBound: "FOR VALUES IN ('eu')"},
+		{Schema: "public", Name: "events_us", Kind: KindPartition, PartitionOf: "public.events", PartitionBound: "FOR VALUES IN ('us')"},
+		{Schema: "public", Name: "events_view", Kind: KindView},
+	}
+	linkPartitions(tables)
+	assert.Len(t, tables[0].Partitions, 2)
+
+	rules := datagen.TablesRules{Rules: map[string]datagen.TableRule{
+		"events": {RowNum: 10, Partitions: []string{"events_us"}},
+	}}
+	assert.NoError(t, ApplyRulesToTables(&tables, rules))
+	assert.Equal(t, "us", tables[0].Columns["region"].DataGen())
+
+	rules.Rules["events_eu"] = datagen.TableRule{RowNum: 10}
+	assert.Error(t, ApplyRulesToTables(&tables, rules))
+
+	delete(rules.Rules, "events_eu")
+	rules.Rules["events_view"] = datagen.TableRule{RowNum: 10}
+	assert.Error(t, ApplyRulesToTables(&tables, rules))
+}
@@ -0,0 +1,351 @@
+package dbutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/samber/mo"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SchemaFileVersion - version of the schema file format written by WriteSchemaFile
+const SchemaFileVersion = 1
+
+// formats of schema files
+const (
+	SchemaFormatJSON = "json"
+	SchemaFormatYAML = "yaml"
+)
+
+// kinds of types in schema files, base types have no kind
+const (
+	typeKindArray       = "array"
+	typeKindEnum        = "enum"
+	typeKindDomain      = "domain"
+	typeKindComposite   = "composite"
+	typeKindUnsupported = "unsupported" // type without a generator, columns of it are written only by rules
+)
+
+// schemaFile - introspected schema saved to a file, tables are restored from it without a database
+type schemaFile struct {
+	Version int           `json:"version" yaml:"version"`
+	Tables  []tableSchema `json:"tables" yaml:"tables"`
+}
+
+type tableSchema struct {
+	Schema         string             `json:"schema" yaml:"schema"`
+	Name           string             `json:"name" yaml:"name"`
+	Kind           string             `json:"kind,omitempty" yaml:"kind,omitempty"`
+	PartitionOf    string             `json:"partition_of,omitempty" yaml:"partition_of,omitempty"`
+	PartitionBound string             `json:"partition_bound,omitempty" yaml:"partition_bound,omitempty"`
+	PartitionKey   string             `json:"partition_key,omitempty" yaml:"partition_key,omitempty"`
+	Columns        []columnSchema     `json:"columns" yaml:"columns"`
+	PrimaryKey     []string           `json:"primary_key,omitempty" yaml:"primary_key,omitempty"`
+	ForeignKeys    []foreignKeySchema `json:"foreign_keys,omitempty" yaml:"foreign_keys,omitempty"`
+	Uniques        []UniqueConstraint `json:"uniques,omitempty" yaml:"uniques,omitempty"`
+	Checks         []CheckConstraint  `json:"checks,omitempty" yaml:"checks,omitempty"`
+}
+
+type columnSchema struct {
+	Name     string     `json:"name" yaml:"name"`
+	Type     typeSchema `json:"type" yaml:"type"`
+	Nullable bool       `json:"nullable" yaml:"nullable"`
+	Default  string     `json:"default,omitempty" yaml:"default,omitempty"`
+	Identity string     `json:"identity,omitempty" yaml:"identity,omitempty"` // ALWAYS or BY DEFAULT
+	// Generated - GENERATED ALWAYS AS (...) STORED column
+	Generated bool `json:"generated,omitempty" yaml:"generated,omitempty"`
+	// Min, Max - values allowed by the declared type, e.g. 0 and 255 of MySQL tinyint unsigned
+	Min *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+type foreignKeySchema struct {
+	Name       string   `json:"name" yaml:"name"`
+	Columns    []string `json:"columns" yaml:"columns"`
+	RefSchema  string   `json:"ref_schema" yaml:"ref_schema"`
+	RefTable   string   `json:"ref_table" yaml:"ref_table"`
+	RefColumns []string `json:"ref_columns" yaml:"ref_columns"`
+	Deferrable bool     `json:"deferrable,omitempty" yaml:"deferrable,omitempty"`
+}
+
+// typeSchema - column type. Base types are stored by name with modifiers, e.g. varchar(20),
+// user-defined types by qualified name together with their definition.
+type typeSchema struct {
+	Name       string            `json:"name" yaml:"name"`
+	Kind       string            `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Elem       *typeSchema       `json:"elem,omitempty" yaml:"elem,omitempty"`     // element type of array
+	Labels     []string          `json:"labels,omitempty" yaml:"labels,omitempty"` // values of enum
+	Base       *typeSchema       `json:"base,omitempty" yaml:"base,omitempty"`     // base type of domain
+	Checks     []CheckConstraint `json:"checks,omitempty" yaml:"checks,omitempty"` // CHECK constraints of domain
+	Attributes []attributeSchema `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+}
+
+type attributeSchema struct {
+	Name string     `json:"name" yaml:"name"`
+	Type typeSchema `json:"type" yaml:"type"`
+}
+
+// SchemaFileFormat returns format of the schema file: the given one, or the one of file extension
+func SchemaFileFormat(path, format string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	switch strings.ToLower(format) {
+	case "json":
+		return SchemaFormatJSON, nil
+	case "yaml", "yml":
+		return SchemaFormatYAML, nil
+	default:
+		return "", fmt.Errorf("unknown schema file format %q of %s, expected %s or %s",
+			format, path, SchemaFormatJSON, SchemaFormatYAML)
+	}
+}
+
+// WriteSchemaFile saves tables to a schema file, format is json or yaml, empty format is taken from file extension
+func WriteSchemaFile(path, format string, tables []Table) error {
+	format, err := SchemaFileFormat(path, format)
+	if err != nil {
+		return err
+	}
+	file := schemaFile{Version: SchemaFileVersion, Tables: make([]tableSchema, 0, len(tables))}
+	for _, table := range tables {
+		file.Tables = append(file.Tables, newTableSchema(table))
+	}
+
+	var data []byte
+	if format == SchemaFormatJSON {
+		data, err = json.MarshalIndent(file, "", "  ")
+	} else {
+		data, err = yaml.Marshal(file)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadSchemaFile restores tables of schemas matching the filter from a schema file
+func LoadSchemaFile(path string, filter SchemaFilter) ([]Table, error) {
+	format, err := SchemaFileFormat(path, "")
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file schemaFile
+	if format == SchemaFormatJSON {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read schema file %s: %v", path, err)
+	}
+	if file.Version < 1 || file.Version > SchemaFileVersion {
+		return nil, fmt.Errorf("schema file %s has version %d, supported versions are 1 to %d",
+			path, file.Version, SchemaFileVersion)
+	}
+
+	tables := make([]Table, 0, len(file.Tables))
+	for _, ts := range file.Tables {
+		if !filter.Match(ts.Schema) {
+			continue
+		}
+		table, err := ts.table()
+		if err != nil {
+			return nil, fmt.Errorf("schema file %s: %v", path, err)
+		}
+		tables = append(tables, table)
+	}
+	linkPartitions(tables)
+	return tables, nil
+}
+
+func newTableSchema(table Table) tableSchema {
+	ts := tableSchema{
+		Schema:         table.Schema,
+		Name:           table.Name,
+		Kind:           table.Kind,
+		PartitionOf:    table.PartitionOf,
+		PartitionBound: table.PartitionBound,
+		PartitionKey:   table.PartitionKey,
+		Columns:        make([]columnSchema, 0, len(table.Columns)),
+		PrimaryKey:     primaryKeyColumns(table),
+		Uniques:        table.Uniques,
+		Checks:         table.Checks,
+	}
+	for _, col := range table.Columns {
+		cs := columnSchema{
+			Name:      col.Name,
+			Type:      newTypeSchema(col.DataType),
+			Nullable:  col.IsNullable,
+			Default:   col.Default,
+			Identity:  col.IdentityGeneration,
+			Generated: col.IsGenerated,
+		}
+		if v, ok := col.TypeRange.Min.Get(); ok {
+			cs.Min = &v
+		}
+		if v, ok := col.TypeRange.Max.Get(); ok {
+			cs.Max = &v
+		}
+		ts.Columns = append(ts.Columns, cs)
+	}
+	sort.Slice(ts.Columns, func(i, j int) bool {
+		return ts.Columns[i].Name < ts.Columns[j].Name
+	})
+	for _, fk := range table.ForeignKeys {
+		ts.ForeignKeys = append(ts.ForeignKeys, foreignKeySchema{
+			Name:       fk.Name,
+			Columns:    fk.Columns,
+			RefSchema:  fk.RefSchema,
+			RefTable:   fk.RefTable,
+			RefColumns: fk.RefColumns,
+			Deferrable: fk.Deferrable,
+		})
+	}
+	return ts
+}
+
+func (ts tableSchema) table() (Table, error) {
+	table := Table{
+		Schema:         ts.Schema,
+		Name:           ts.Name,
+		Kind:           ts.Kind,
+		PartitionOf:    ts.PartitionOf,
+		PartitionBound: ts.PartitionBound,
+		PartitionKey:   ts.PartitionKey,
+		Columns:        make(map[string]Column),
+		PrimaryKeys:    make(map[string]bool),
+		ForeignKeys:    make([]ForeignKey, 0),
+		Uniques:        ts.Uniques,
+		Checks:         ts.Checks,
+		RowNum:         0,
+		Rules:          make(map[string]func() string),
+	}
+	if table.Kind == "" {
+		table.Kind = KindTable
+	}
+	if table.Uniques == nil {
+		table.Uniques = make([]UniqueConstraint, 0)
+	}
+	if table.Checks == nil {
+		table.Checks = make([]CheckConstraint, 0)
+	}
+
+	for _, cs := range ts.Columns {
+		dataType, err := cs.Type.dataType()
+		if err != nil {
+			return Table{}, fmt.Errorf("column %s.%s: %v", table.QualifiedName(), cs.Name, err)
+		}
+		col := Column{
+			Name:               cs.Name,
+			DataType:           dataType,
+			IsNullable:         cs.Nullable,
+			Default:            cs.Default,
+			IsIdentity:         cs.Identity != "",
+			IdentityGeneration: cs.Identity,
+			IsGenerated:        cs.Generated,
+		}
+		if cs.Min != nil {
+			col.TypeRange.Min = mo.Some(*cs.Min)
+		}
+		if cs.Max != nil {
+			col.TypeRange.Max = mo.Some(*cs.Max)
+		}
+		col.Check = col.TypeRange
+		table.Columns[cs.Name] = col
+	}
+	for _, colName := range ts.PrimaryKey {
+		if _, ok := table.Columns[colName]; !ok {
+			return Table{}, fmt.Errorf("primary key column %s.%s not found", table.QualifiedName(), colName)
+		}
+		table.PrimaryKeys[colName] = true
+	}
+	for _, fk := range ts.ForeignKeys {
+		if len(fk.Columns) == 0 || len(fk.Columns) != len(fk.RefColumns) {
+			return Table{}, fmt.Errorf("foreign key %s of %s must have as many columns as referenced columns",
+				fk.Name, table.QualifiedName())
+		}
+		table.ForeignKeys = append(table.ForeignKeys, ForeignKey{
+			Name:       fk.Name,
+			Columns:    fk.Columns,
+			RefSchema:  fk.RefSchema,
+			RefTable:   fk.RefTable,
+			RefColumns: fk.RefColumns,
+			Deferrable: fk.Deferrable,
+		})
+	}
+	completeTable(&table)
+	return table, nil
+}
+
+func newTypeSchema(dataType DataType) typeSchema {
+	ts := typeSchema{Name: TypeName(dataType)}
+	switch t := dataType.(type) {
+	case Array:
+		elem := newTypeSchema(t.Elem)
+		ts.Kind, ts.Elem = typeKindArray, &elem
+	case Enum:
+		ts.Kind, ts.Labels = typeKindEnum, t.Labels
+	case Domain:
+		base := newTypeSchema(t.Base)
+		ts.Kind, ts.Base, ts.Checks = typeKindDomain, &base, t.Checks
+	case Unsupported:
+		ts.Kind = typeKindUnsupported
+	case Composite:
+		ts.Kind = typeKindComposite
+		ts.Attributes = make([]attributeSchema, 0, len(t.Attributes))
+		for _, attr := range t.Attributes {
+			ts.Attributes = append(ts.Attributes, attributeSchema{Name: attr.Name, Type: newTypeSchema(attr.DataType)})
+		}
+	}
+	return ts
+}
+
+func (ts typeSchema) dataType() (DataType, error) {
+	switch ts.Kind {
+	case "":
+		return StringToDataType(ts.Name)
+	case typeKindArray:
+		if ts.Elem == nil {
+			return nil, fmt.Errorf("array type %s has no element type", ts.Name)
+		}
+		elem, err := ts.Elem.dataType()
+		if err != nil {
+			return nil, err
+		}
+		return Array{Elem: elem}, nil
+	case typeKindEnum:
+		return Enum{Name: ts.Name, Labels: ts.Labels}, nil
+	case typeKindDomain:
+		if ts.Base == nil {
+			return nil, fmt.Errorf("domain %s has no base type", ts.Name)
+		}
+		base, err := ts.Base.dataType()
+		if err != nil {
+			return nil, err
+		}
+		return NewDomain(ts.Name, base, ts.Checks), nil
+	case typeKindComposite:
+		composite := Composite{Name: ts.Name, Attributes: make([]CompositeAttribute, 0, len(ts.Attributes))}
+		for _, attr := range ts.Attributes {
+			dataType, err := attr.Type.dataType()
+			if err != nil {
+				return nil, fmt.Errorf("attribute %s of %s: %v", attr.Name, ts.Name, err)
+			}
+			composite.Attributes = append(composite.Attributes, CompositeAttribute{Name: attr.Name, DataType: dataType})
+		}
+		return composite, nil
+	case typeKindUnsupported:
+		return Unsupported{Name: ts.Name}, nil
+	default:
+		return nil, fmt.Errorf("unknown kind %s of type %s", ts.Kind, ts.Name)
+	}
+}
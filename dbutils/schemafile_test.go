@@ -0,0 +1,91 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestSchemaFile_RoundTrip(t *testing.T) {
+	tables, err := newTestCatalog().tables(SchemaFilter{})
+	assert.NoError(t, err)
+
+	for _, name := range []string{"schema.json", "schema.yaml"} {
+		path := filepath.Join(t.TempDir(), name)
+		assert.NoError(t, WriteSchemaFile(path, "", tables))
+
+		loaded, err := LoadSchemaFile(path, SchemaFilter{})
+		assert.NoError(t, err, name)
+		assert.Len(t, loaded, len(tables), name)
+		for i := range tables {
+			assert.Equal(t, newTableSchema(tables[i]), newTableSchema(loaded[i]), name)
+			assert.Equal(t, tables[i].DependsOn, loaded[i].DependsOn, name)
+		}
+
+		orders := loaded[1]
+		assert.True(t, orders.Columns["customer_id"].IsForeignKey, name)
+		assert.Equal(t, 0.0, orders.Columns["customer_id"].Check.Min.OrElse(-1), name)
+		assert.True(t, orders.Columns["amount"].Check.Min.IsPresent(), name)
+		assert.NotNil(t, orders.Columns["address"].DataGen, name)
+
+		shop, err := LoadSchemaFile(path, SchemaFilter{Exclude: []string{"audit"}})
+		assert.NoError(t, err, name)
+		assert.Len(t, shop, 2, name)
+	}
+}
+
+func TestSchemaFile_TypeRange(t *testing.T) {
+	sqliteTables, err := SQLiteDialect{}.Introspect(openSQLiteTestDB(t), SchemaFilter{})
+	assert.NoError(t, err)
+	mysqlCatalog := newMySQLCatalog()
+	mysqlCatalog.relations = []mysqlRelation{{schema: "shop", name: "items", kind: "BASE TABLE"}}
+	mysqlCatalog.columns["shop.items"] = []mysqlColumn{
+		{name: "stock", columnType: "tinyint unsigned", nullable: "NO"},
+		{name: "flag", columnType: "tinyint(1)", nullable: "NO"},
+	}
+	mysqlTables, err := mysqlCatalog.tables(SchemaFilter{})
+	assert.NoError(t, err)
+
+	for _, name := range []string{"schema.json", "schema.yaml"} {
+		path := filepath.Join(t.TempDir(), name)
+		assert.NoError(t, WriteSchemaFile(path, "", append(sqliteTables, mysqlTables...)))
+		loaded, err := LoadSchemaFile(path, SchemaFilter{})
+		assert.NoError(t, err, name)
+
+		ranges := map[string][2]int{"customers.active": {0, 1}, "items.stock": {0, 255}, "items.flag": {0, 1}}
+		for _, table := range loaded {
+			for _, col := range table.Columns {
+				r, ok := ranges[table.Name+"."+col.Name]
+				if !ok {
+					continue
+				}
+				assert.Equal(t, float64(r[0]), col.Check.Min.OrEmpty(), name)
+				assert.Equal(t, float64(r[1]), col.Check.Max.OrEmpty(), name)
+				for i := 0; i < 100; i++ {
+					v, err := strconv.Atoi(col.DataGen())
+					assert.NoError(t, err, name)
+					assert.True(t, v >= r[0] && v <= r[1], "%s %s.%s = %d", name, table.Name, col.Name, v)
+				}
+			}
+		}
+		// the range is kept with CHECK constraints applied on top of it
+		for _, table := range loaded {
+			if table.Name == "orders" {
+				assert.True(t, table.Columns["quantity"].Check.MinStrict, name)
+				assert.True(t, table.Columns["quantity"].TypeRange.IsEmpty(), name)
+			}
+		}
+	}
+}
+
+func TestLoadSchemaFile_Version(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"version": 2, "tables": []}`), 0644))
+	_, err := LoadSchemaFile(path, SchemaFilter{})
+	assert.ErrorContains(t, err, "version 2")
+
+	_, err = LoadSchemaFile(filepath.Join(t.TempDir(), "schema.txt"), SchemaFilter{})
+	assert.ErrorContains(t, err, "unknown schema file format")
+}
@@ -0,0 +1,261 @@
+package dbutils
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const (
+	writeSavepoint = "db_faker_write" // savepoint around a write in a transaction of a non-atomic session
+	savepointRows  = 1000             // rows of single-row INSERTs sharing one savepoint
+)
+
+// Session - connection of one generation run, it decides when written rows are committed:
+// after every statement, in transactions of commitEvery rows, or, if atomic, in one transaction
+// for the whole run, committed by Commit.
+// Session is a Querier, reads go to the open transaction and see rows written by it.
+type Session struct {
+	db          *sql.DB
+	tx          *sql.Tx
+	atomic      bool
+	commitEvery int
+	uncommitted int                  // rows written by the open transaction
+	pending     map[string]int       // rows written by the open transaction by table
+	committed   map[string]int       // committed rows by table
+	stmts       map[string]*sql.Stmt // statements prepared on the open transaction, or on db
+}
+
+// NewSession starts a session on db. With atomic the run is one transaction, with commitEvery > 0
+// rows are committed every commitEvery rows, otherwise every statement is committed by itself.
+func NewSession(db *sql.DB, atomic bool, commitEvery int) (*Session, error) {
+	if commitEvery < 0 {
+		return nil, fmt.Errorf("number of rows per commit must be positive, got %d", commitEvery)
+	}
+	if atomic && commitEvery > 0 {
+		return nil, fmt.Errorf("atomic run is committed once, it can't be committed every %d rows", commitEvery)
+	}
+	s := &Session{
+		db:          db,
+		atomic:      atomic,
+		commitEvery: commitEvery,
+		pending:     make(map[string]int),
+		committed:   make(map[string]int),
+		stmts:       make(map[string]*sql.Stmt),
+	}
+	if atomic || commitEvery > 0 {
+		if err := s.begin(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Atomic reports whether the run is one transaction, then any failed row fails the run
+func (s *Session) Atomic() bool {
+	return s.atomic
+}
+
+// Committed returns the number of committed rows of the table
+func (s *Session) Committed(table Table) int {
+	return s.committed[table.QualifiedName()]
+}
+
+func (s *Session) querier() Querier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+func (s *Session) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.querier().Exec(query, args...)
+}
+
+func (s *Session) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.querier().Query(query, args...)
+}
+
+func (s *Session) QueryRow(query string, args ...interface{}) *sql.Row {
+	return s.querier().QueryRow(query, args...)
+}
+
+func (s *Session) Prepare(query string) (*sql.Stmt, error) {
+	return s.querier().Prepare(query)
+}
+
+// prepare returns the query prepared on the open transaction, statements are reused until it ends
+func (s *Session) prepare(query string) (*sql.Stmt, error) {
+	if stmt, ok := s.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := s.querier().Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	s.stmts[query] = stmt
+	return stmt, nil
+}
+
+func (s *Session) closeStmts() {
+	for query, stmt := range s.stmts {
+		_ = stmt.Close()
+		delete(s.stmts, query)
+	}
+}
+
+func (s *Session) begin() error {
+	s.closeStmts()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	s.tx = tx
+	return nil
+}
+
+// chunkRows returns number of rows inserted one by one under one savepoint. In a transaction of
+// a non-atomic session a savepoint per row would triple round trips, so rows share a savepoint
+// and are retried row by row only if one of them fails.
+func (s *Session) chunkRows() int {
+	if s.tx == nil || s.atomic {
+		return 1
+	}
+	if s.commitEvery > 0 {
+		return min(savepointRows, s.commitEvery)
+	}
+	return savepointRows
+}
+
+// write runs fn writing rows on the open transaction, or on db if there is none.
+// In a transaction of a non-atomic session fn runs in a savepoint, so a failed statement
+// doesn't abort the transaction and lose rows written before it.
+func (s *Session) write(fn func(q Querier) error) error {
+	if s.tx == nil || s.atomic {
+		return fn(s.querier())
+	}
+	if _, err := s.tx.Exec("SAVEPOINT " + writeSavepoint); err != nil {
+		return err
+	}
+	if err := fn(s.tx); err != nil {
+		if _, rbErr := s.tx.Exec("ROLLBACK TO SAVEPOINT " + writeSavepoint); rbErr != nil {
+			return fmt.Errorf("%v, rollback to savepoint: %v", err, rbErr)
+		}
+		_, _ = s.tx.Exec("RELEASE SAVEPOINT " + writeSavepoint)
+		return err
+	}
+	_, err := s.tx.Exec("RELEASE SAVEPOINT " + writeSavepoint)
+	return err
+}
+
+// written counts rows of the table written by write and commits them when the transaction
+// has commitEvery rows
+func (s *Session) written(table Table, rows int) error {
+	if s.tx == nil {
+		s.committed[table.QualifiedName()] += rows
+		return nil
+	}
+	s.pending[table.QualifiedName()] += rows
+	s.uncommitted += rows
+	if s.commitEvery > 0 && s.uncommitted >= s.commitEvery {
+		return s.commit(true)
+	}
+	return nil
+}
+
+// commit commits the open transaction, with restart the next one is begun
+func (s *Session) commit(restart bool) error {
+	if s.tx == nil {
+		return nil
+	}
+	s.closeStmts()
+	err := s.tx.Commit()
+	s.tx = nil
+	if err == nil {
+		for name, rows := range s.pending {
+			s.committed[name] += rows
+		}
+	}
+	s.pending = make(map[string]int)
+	s.uncommitted = 0
+	if err != nil {
+		return err
+	}
+	if restart {
+		return s.begin()
+	}
+	return nil
+}
+
+// flush commits rows written by the open transaction of a non-atomic session, so other sessions see them
+func (s *Session) flush() error {
+	if s.atomic {
+		return nil
+	}
+	return s.commit(s.commitEvery > 0)
+}
+
+// deferred runs fn in one transaction with constraints deferred until its commit.
+// An atomic session runs fn in its transaction, otherwise rows written before are committed
+// and fn gets a transaction of its own, which is rolled back if fn fails.
+func (s *Session) deferred(fn func() error) error {
+	if s.atomic {
+		if _, err := s.tx.Exec("SET CONSTRAINTS ALL DEFERRED"); err != nil {
+			return err
+		}
+		return fn()
+	}
+
+	if err := s.commit(false); err != nil {
+		return err
+	}
+	if err := s.begin(); err != nil {
+		return err
+	}
+	// no commits until fn is done
+	commitEvery := s.commitEvery
+	s.commitEvery = 0
+	defer func() { s.commitEvery = commitEvery }()
+
+	if _, err := s.tx.Exec("SET CONSTRAINTS ALL DEFERRED"); err != nil {
+		_ = s.rollback(commitEvery > 0)
+		return err
+	}
+	if err := fn(); err != nil {
+		_ = s.rollback(commitEvery > 0)
+		return err
+	}
+	return s.commit(commitEvery > 0)
+}
+
+// rollback rolls back the open transaction, with restart the next one is begun
+func (s *Session) rollback(restart bool) error {
+	if s.tx == nil {
+		return nil
+	}
+	s.closeStmts()
+	err := s.tx.Rollback()
+	s.tx = nil
+	s.pending = make(map[string]int)
+	s.uncommitted = 0
+	if err != nil {
+		return err
+	}
+	if restart {
+		return s.begin()
+	}
+	return nil
+}
+
+// Commit commits rows written since the last commit, it ends the session
+func (s *Session) Commit() error {
+	err := s.commit(false)
+	s.closeStmts()
+	return err
+}
+
+// Rollback discards rows written since the last commit, it ends the session
+func (s *Session) Rollback() error {
+	err := s.rollback(false)
+	s.closeStmts()
+	return err
+}
@@ -0,0 +1,69 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewSession(t *testing.T) {
+	db := openSQLiteTestDB(t)
+	_, err := NewSession(db, true, 100)
+	assert.ErrorContains(t, err, "can't be committed every 100 rows")
+	_, err = NewSession(db, false, -1)
+	assert.ErrorContains(t, err, "must be positive")
+}
+
+func TestSession_AtomicRollback(t *testing.T) {
+	db := openSQLiteTestDB(t)
+	_, err := db.Exec(`CREATE TABLE rejected (n INTEGER NOT NULL CHECK (n <> n))`)
+	assert.NoError(t, err)
+	customers := introspectSQLiteTable(t, db, "customers")
+	customers.RowNum = 10
+	rejected := introspectSQLiteTable(t, db, "rejected")
+	rejected.RowNum = 50
+
+	session, err := NewSession(db, true, 0)
+	assert.NoError(t, err)
+	opts := LoadOptions{Dialect: SQLiteDialect{}, Method: LoadBatch, BatchSize: 10}
+	inserted, err := GenerateAndInsertData(session, opts, customers)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, inserted)
+	// a failed chunk fails the run instead of falling back to row by row
+	_, err = GenerateAndInsertData(session, opts, rejected)
+	assert.ErrorContains(t, err, "rows 0-9")
+	assert.NoError(t, session.Rollback())
+
+	for _, table := range []Table{customers, rejected} {
+		var count int
+		assert.NoError(t, db.QueryRow("SELECT count(*) FROM "+table.Name).Scan(&count))
+		assert.Zero(t, count, table.Name)
+		assert.Zero(t, session.Committed(table), table.Name)
+	}
+}
+
+func TestSession_CommitEvery(t *testing.T) {
+	db := openSQLiteTestDB(t)
+	_, err := db.Exec(`CREATE TABLE numbers (n INTEGER NOT NULL CHECK (n % 2 = 0))`)
+	assert.NoError(t, err)
+	numbers := introspectSQLiteTable(t, db, "numbers")
+	numbers.RowNum = 100
+
+	session, err := NewSession(db, false, 10)
+	assert.NoError(t, err)
+	// single-row INSERTs share a savepoint until the next commit
+	assert.Equal(t, 10, session.chunkRows())
+	inserted, err := GenerateAndInsertData(session, LoadOptions{Dialect: SQLiteDialect{}, Method: LoadInsert}, numbers)
+	assert.NoError(t, err)
+
+	// failed chunks are rolled back to their savepoint and retried row by row,
+	// rows of the open transaction are not committed yet
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT count(*) FROM numbers").Scan(&count))
+	assert.Equal(t, session.Committed(numbers), count)
+	assert.Less(t, inserted-count, 10)
+
+	assert.NoError(t, session.Commit())
+	assert.NoError(t, db.QueryRow("SELECT count(*) FROM numbers").Scan(&count))
+	assert.Equal(t, inserted, count)
+	assert.Equal(t, inserted, session.Committed(numbers))
+}
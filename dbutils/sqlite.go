@@ -0,0 +1,427 @@
+package dbutils
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/samber/mo"
+)
+
+// sqliteSchema - schema of tables of the main database file
+const sqliteSchema = "main"
+
+// Queries of the SQLite snapshot, pragma functions are joined with sqlite_master to read all tables at once
+const (
+	sqliteTablesCondition = `
+			m.type IN ('table', 'view')
+			AND m.name NOT LIKE 'sqlite\_%' ESCAPE '\'
+			AND m.sql NOT LIKE 'CREATE VIRTUAL %'`
+
+	getSQLiteRelationsQuery = `
+		SELECT m.name, m.type, m.sql
+		FROM sqlite_master m
+		WHERE` + sqliteTablesCondition + `
+		ORDER BY m.name
+	`
+
+	getSQLiteColumnsQuery = `
+		SELECT m.name, p.name, p.type, p."notnull", COALESCE(p.dflt_value, ''), p.pk
+		FROM sqlite_master m
+		JOIN pragma_table_info(m.name) p
+		WHERE` + sqliteTablesCondition + `
+		ORDER BY m.name, p.cid
+	`
+
+	getSQLiteForeignKeysQuery = `
+		SELECT m.name, f.id, f."table", f."from", COALESCE(f."to", '')
+		FROM sqlite_master m
+		JOIN pragma_foreign_key_list(m.name) f
+		WHERE` + sqliteTablesCondition + `
+		ORDER BY m.name, f.id, f.seq
+	`
+
+	// unique indexes on columns, including the primary key. Partial indexes are taken as full ones,
+	// which is stricter than the database.
+	getSQLiteUniqueIndexesQuery = `
+		SELECT m.name, il.name, COALESCE(ii.name, '')
+		FROM sqlite_master m
+		JOIN pragma_index_list(m.name) il
+		JOIN pragma_index_info(il.name) ii
+		WHERE il."unique"
+			AND` + sqliteTablesCondition + `
+		ORDER BY m.name, il.name, ii.seqno
+	`
+)
+
+var (
+	sqliteTypePattern  = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_ ]*?)?\s*(?:\(\s*([+-]?\d+)\s*(?:,\s*([+-]?\d+)\s*)?\))?$`)
+	sqliteWithoutRowID = regexp.MustCompile(`(?i)\bWITHOUT\s+ROWID\b`)
+)
+
+// SQLiteDialect - SQLite database file through mattn/go-sqlite3.
+// Tables of the file are in the main schema.
+type SQLiteDialect struct{}
+
+func (SQLiteDialect) Name() string {
+	return "sqlite"
+}
+
+func (SQLiteDialect) DriverName() string {
+	return "sqlite3"
+}
+
+// DataSourceName returns path of the database file, foreign keys are enforced
+func (SQLiteDialect) DataSourceName(conn ConnConfig) (string, error) {
+	if conn.File == "" {
+		return "", fmt.Errorf("sqlite needs a database file")
+	}
+	return "file:" + conn.File + "?_foreign_keys=on", nil
+}
+
+func (SQLiteDialect) DefaultPort() int {
+	return 0
+}
+
+func (SQLiteDialect) Introspect(db *sql.DB, filter SchemaFilter) ([]Table, error) {
+	s, err := loadSQLiteCatalog(db)
+	if err != nil {
+		return nil, err
+	}
+	return s.tables(filter), nil
+}
+
+// ColumnType converts declared type of a column. SQLite accepts any type name, known names are mapped
+// to their data types, others by the affinity rules of SQLite: INTEGER, TEXT, BLOB, REAL or NUMERIC.
+func (SQLiteDialect) ColumnType(columnType string) (DataType, ColumnCheck, error) {
+	m := sqliteTypePattern.FindStringSubmatch(strings.TrimSpace(columnType))
+	if m == nil {
+		// unusual declarations still get the affinity of their name
+		m = []string{columnType, columnType, "", ""}
+	}
+	name := strings.ToLower(whitespacePattern.ReplaceAllString(strings.TrimSpace(m[1]), " "))
+	param := func(s string) mo.Option[int] {
+		if n, err := strconv.Atoi(s); err == nil {
+			return mo.Some(n)
+		}
+		return mo.None[int]()
+	}
+	length := param(m[2])
+
+	switch name {
+	case "boolean", "bool":
+		return Int{}, ColumnCheck{Min: mo.Some(0.0), Max: mo.Some(1.0)}, nil
+	case "tinyint":
+		return Int{}, ColumnCheck{Min: mo.Some(-128.0), Max: mo.Some(127.0)}, nil
+	case "smallint", "int2":
+		return SmallInt{}, ColumnCheck{}, nil
+	case "bigint", "int8", "unsigned big int":
+		return BigInt{}, ColumnCheck{}, nil
+	case "date":
+		return Date{}, ColumnCheck{}, nil
+	case "datetime", "timestamp":
+		return TimeStamp{}, ColumnCheck{}, nil
+	case "time":
+		return Time{}, ColumnCheck{}, nil
+	case "json":
+		return JSON{}, ColumnCheck{}, nil
+	case "uuid":
+		return UUID{}, ColumnCheck{}, nil
+	}
+
+	switch {
+	case strings.Contains(name, "int"):
+		return Int{}, ColumnCheck{}, nil
+	case strings.Contains(name, "char") || strings.Contains(name, "clob") || strings.Contains(name, "text"):
+		switch {
+		case !length.IsPresent():
+			return Text{}, ColumnCheck{}, nil
+		case strings.Contains(name, "var"):
+			return VarChar{MaxLen: length}, ColumnCheck{}, nil
+		default:
+			return Char{Len: length}, ColumnCheck{}, nil
+		}
+	case name == "" || strings.Contains(name, "blob"):
+		return Text{}, ColumnCheck{}, nil
+	case strings.Contains(name, "real") || strings.Contains(name, "floa") || strings.Contains(name, "doub"):
+		return Float8{}, ColumnCheck{}, nil
+	default:
+		return Numeric{Precision: length, Scale: param(m[3])}, ColumnCheck{}, nil
+	}
+}
+
+func (SQLiteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (SQLiteDialect) QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (SQLiteDialect) RandomOrder() string {
+	return "RANDOM()"
+}
+
+// Returning reports support of RETURNING, SQLite has it since 3.35
+func (SQLiteDialect) Returning() bool {
+	return true
+}
+
+func (SQLiteDialect) Copy() bool {
+	return false
+}
+
+// MaxParams returns the default limit of SQLite since 3.32
+func (SQLiteDialect) MaxParams() int {
+	return 32766
+}
+
+func (SQLiteDialect) DefaultRow() string {
+	return "DEFAULT VALUES"
+}
+
+// sqliteRelation - table or view from sqlite_master
+type sqliteRelation struct {
+	name string
+	kind string
+	sql  string // CREATE statement
+}
+
+// sqliteColumn - column from pragma_table_info
+type sqliteColumn struct {
+	name     string
+	declared string // declared type, may be empty
+	notNull  bool
+	dflt     string
+	pk       int // position in the primary key counting from 1, 0 if the column is not in it
+}
+
+// sqliteForeignKey - foreign key from pragma_foreign_key_list
+type sqliteForeignKey struct {
+	id         int
+	refTable   string
+	columns    []string
+	refColumns []string // empty if the key references the primary key
+}
+
+// sqliteCatalog - snapshot of the database file, objects are grouped by table name
+type sqliteCatalog struct {
+	relations   []sqliteRelation
+	columns     map[string][]sqliteColumn
+	foreignKeys map[string][]sqliteForeignKey
+	indexes     map[string][]UniqueConstraint
+}
+
+func newSQLiteCatalog() *sqliteCatalog {
+	return &sqliteCatalog{
+		columns:     make(map[string][]sqliteColumn),
+		foreignKeys: make(map[string][]sqliteForeignKey),
+		indexes:     make(map[string][]UniqueConstraint),
+	}
+}
+
+// loadSQLiteCatalog reads the snapshot of the database file
+func loadSQLiteCatalog(db *sql.DB) (*sqliteCatalog, error) {
+	s := newSQLiteCatalog()
+	loaders := []struct {
+		name  string
+		query string
+		scan  func(rows *sql.Rows) error
+	}{
+		{"relations", getSQLiteRelationsQuery, s.scanRelation},
+		{"columns", getSQLiteColumnsQuery, s.scanColumn},
+		{"foreign keys", getSQLiteForeignKeysQuery, s.scanForeignKey},
+		{"unique indexes", getSQLiteUniqueIndexesQuery, s.scanIndex},
+	}
+	for _, loader := range loaders {
+		if err := queryRows(db, loader.query, loader.scan); err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", loader.name, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *sqliteCatalog) scanRelation(rows *sql.Rows) error {
+	var r sqliteRelation
+	if err := rows.Scan(&r.name, &r.kind, &r.sql); err != nil {
+		return err
+	}
+	s.relations = append(s.relations, r)
+	return nil
+}
+
+func (s *sqliteCatalog) scanColumn(rows *sql.Rows) error {
+	var table string
+	var c sqliteColumn
+	if err := rows.Scan(&table, &c.name, &c.declared, &c.notNull, &c.dflt, &c.pk); err != nil {
+		return err
+	}
+	s.columns[table] = append(s.columns[table], c)
+	return nil
+}
+
+func (s *sqliteCatalog) scanForeignKey(rows *sql.Rows) error {
+	var table, col, refCol string
+	var k sqliteForeignKey
+	if err := rows.Scan(&table, &k.id, &k.refTable, &col, &refCol); err != nil {
+		return err
+	}
+	s.addForeignKey(table, k, col, refCol)
+	return nil
+}
+
+// addForeignKey adds a column of a foreign key, rows are ordered by key id, so columns of one key are adjacent
+func (s *sqliteCatalog) addForeignKey(table string, k sqliteForeignKey, col, refCol string) {
+	keys := s.foreignKeys[table]
+	if len(keys) == 0 || keys[len(keys)-1].id != k.id {
+		keys = append(keys, k)
+	}
+	last := &keys[len(keys)-1]
+	last.columns = append(last.columns, col)
+	if refCol != "" {
+		last.refColumns = append(last.refColumns, refCol)
+	}
+	s.foreignKeys[table] = keys
+}
+
+func (s *sqliteCatalog) scanIndex(rows *sql.Rows) error {
+	var table, index, col string
+	if err := rows.Scan(&table, &index, &col); err != nil {
+		return err
+	}
+	indexes := s.indexes[table]
+	if len(indexes) == 0 || indexes[len(indexes)-1].Name != index {
+		indexes = append(indexes, UniqueConstraint{Name: index})
+	}
+	last := &indexes[len(indexes)-1]
+	last.Columns = append(last.Columns, col)
+	s.indexes[table] = indexes
+	return nil
+}
+
+// tables builds tables of the main schema if it passes the filter
+func (s *sqliteCatalog) tables(filter SchemaFilter) []Table {
+	tables := make([]Table, 0)
+	if !filter.Match(sqliteSchema) {
+		return tables
+	}
+	for _, r := range s.relations {
+		tables = append(tables, s.table(r))
+	}
+	return tables
+}
+
+func (s *sqliteCatalog) table(r sqliteRelation) Table {
+	table := Table{
+		Schema:      sqliteSchema,
+		Name:        r.name,
+		Kind:        KindTable,
+		Columns:     make(map[string]Column),
+		PrimaryKeys: make(map[string]bool),
+		ForeignKeys: make([]ForeignKey, 0),
+		Uniques:     make([]UniqueConstraint, 0),
+		Checks:      sqliteChecks(r.name, r.sql),
+		RowNum:      0,
+		Rules:       make(map[string]func() string),
+	}
+	if r.kind == "view" {
+		table.Kind = KindView
+	}
+	for _, index := range s.indexes[r.name] {
+		// indexes on expressions have no column name
+		if !containsString(index.Columns, "") {
+			table.Uniques = append(table.Uniques, index)
+		}
+	}
+
+	pkCols := s.primaryKey(r.name)
+	for _, c := range s.columns[r.name] {
+		dataType, check, _ := SQLiteDialect{}.ColumnType(c.declared)
+		col := Column{
+			Name:       c.name,
+			DataType:   dataType,
+			IsNullable: !c.notNull && c.pk == 0,
+			Default:    c.dflt,
+			Check:      check,
+			TypeRange:  check,
+		}
+		// INTEGER PRIMARY KEY is an alias of rowid and gets the next rowid if not written
+		if len(pkCols) == 1 && c.pk == 1 && strings.EqualFold(c.declared, "INTEGER") &&
+			!sqliteWithoutRowID.MatchString(r.sql) {
+			col.IsIdentity = true
+			col.IdentityGeneration = "BY DEFAULT"
+		}
+		table.Columns[c.name] = col
+	}
+	for _, colName := range pkCols {
+		table.PrimaryKeys[colName] = true
+	}
+
+	for _, k := range s.foreignKeys[r.name] {
+		refColumns := k.refColumns
+		if len(refColumns) == 0 {
+			refColumns = s.primaryKey(k.refTable)
+		}
+		table.ForeignKeys = append(table.ForeignKeys, ForeignKey{
+			Name:       fmt.Sprintf("%s_%s_fkey", r.name, strings.Join(k.columns, "_")),
+			Columns:    k.columns,
+			RefSchema:  sqliteSchema,
+			RefTable:   k.refTable,
+			RefColumns: refColumns,
+		})
+	}
+	completeTable(&table)
+	return table
+}
+
+// primaryKey returns primary key columns of the table in key order
+func (s *sqliteCatalog) primaryKey(table string) []string {
+	columns := s.columns[table]
+	pkCols := make([]string, 0)
+	for pos := 1; ; pos++ {
+		found := false
+		for _, c := range columns {
+			if c.pk == pos {
+				pkCols = append(pkCols, c.name)
+				found = true
+			}
+		}
+		if !found {
+			return pkCols
+		}
+	}
+}
+
+// sqliteChecks extracts CHECK constraints from CREATE TABLE statement, SQLite doesn't list them separately.
+// Constraints without name are named like in PostgreSQL, e.g. orders_check, orders_check1.
+func sqliteChecks(table, createSQL string) []CheckConstraint {
+	checks := make([]CheckConstraint, 0)
+	p := newDDLParser(createSQL)
+	name := ""
+	for !p.done() {
+		switch {
+		case p.accept("CONSTRAINT"):
+			if ident, err := p.ident(); err == nil && p.isWord("CHECK") {
+				name = ident
+			}
+		case p.accept("CHECK") && p.isSymbol("("):
+			inner, err := p.group()
+			if err != nil {
+				return checks
+			}
+			if name == "" {
+				name = table + "_check"
+				if n := len(checks); n > 0 {
+					name += strconv.Itoa(n)
+				}
+			}
+			checks = append(checks, CheckConstraint{Name: name, Definition: "CHECK (" + p.text(inner) + ")"})
+			name = ""
+		default:
+			p.next()
+		}
+	}
+	return checks
+}
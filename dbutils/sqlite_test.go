@@ -0,0 +1,212 @@
+package dbutils
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/samber/mo"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+const sqliteTestSchema = `
+	CREATE TABLE customers (
+		id INTEGER PRIMARY KEY,
+		email VARCHAR(100) NOT NULL UNIQUE,
+		active BOOLEAN NOT NULL DEFAULT 1
+	);
+	CREATE TABLE orders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		customer_id INTEGER NOT NULL REFERENCES customers,
+		quantity INT NOT NULL,
+		price DECIMAL(10, 2),
+		note,
+		CONSTRAINT positive_quantity CHECK (quantity > 0)
+	);
+	CREATE TABLE order_tags (
+		order_id INTEGER NOT NULL REFERENCES orders (id),
+		tag TEXT NOT NULL CHECK (length(tag) <= 10),
+		PRIMARY KEY (order_id, tag)
+	) WITHOUT ROWID;
+	CREATE VIEW order_totals AS SELECT customer_id, sum(quantity) AS quantity FROM orders GROUP BY customer_id;
+`
+
+func openSQLiteTestDB(t *testing.T) *sql.DB {
+	dsn, err := SQLiteDialect{}.DataSourceName(ConnConfig{File: filepath.Join(t.TempDir(), "app.db")})
+	assert.NoError(t, err)
+	db, err := sql.Open("sqlite3", dsn)
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec(sqliteTestSchema)
+	assert.NoError(t, err)
+	return db
+}
+
+// introspectSQLiteTable returns the table of the test database with name
+func introspectSQLiteTable(t *testing.T, db *sql.DB, name string) Table {
+	tables, err := SQLiteDialect{}.Introspect(db, SchemaFilter{})
+	assert.NoError(t, err)
+	for _, table := range tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	t.Fatalf("table %s not found", name)
+	return Table{}
+}
+
+func TestSQLiteDialect_ColumnType(t *testing.T) {
+	cases := []struct {
+		columnType string
+		dataType   DataType
+	}{
+		{"INTEGER", Int{}},
+		{"BIGINT", BigInt{}},
+		{"MEDIUMINT", Int{}},
+		{"VARCHAR(50)", VarChar{MaxLen: mo.Some(50)}},
+		{"NCHAR(2)", Char{Len: mo.Some(2)}},
+		{"CLOB", Text{}},
+		{"", Text{}},
+		{"BLOB", Text{}},
+		{"DOUBLE PRECISION", Float8{}},
+		{"DECIMAL(10,2)", Numeric{Precision: mo.Some(10), Scale: mo.Some(2)}},
+		{"DATETIME", TimeStamp{}},
+		{"whatever", Numeric{}},
+	}
+	for _, c := range cases {
+		dataType, _, err := SQLiteDialect{}.ColumnType(c.columnType)
+		assert.NoError(t, err, c.columnType)
+		assert.Equal(t, c.dataType, dataType, c.columnType)
+	}
+}
+
+func TestSQLiteDialect_Introspect(t *testing.T) {
+	db := openSQLiteTestDB(t)
+	tables, err := SQLiteDialect{}.Introspect(db, SchemaFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, tables, 4)
+
+	byName := make(map[string]Table)
+	for _, table := range tables {
+		byName[table.Name] = table
+	}
+	customers, orders, tags := byName["customers"], byName["orders"], byName["order_tags"]
+	assert.Equal(t, KindView, byName["order_totals"].Kind)
+
+	assert.Equal(t, "main.customers", customers.QualifiedName())
+	assert.True(t, customers.Columns["id"].IsIdentity)
+	assert.Equal(t, 1.0, customers.Columns["active"].Check.Max.OrElse(0))
+	assert.Equal(t, VarChar{MaxLen: mo.Some(100)}, customers.Columns["email"].DataType)
+	assert.Len(t, customers.Uniques, 1)
+
+	assert.True(t, orders.Columns["id"].IsIdentity)
+	assert.Equal(t, []string{"main.customers"}, orders.DependsOn)
+	assert.Equal(t, []string{"id"}, orders.ForeignKeys[0].RefColumns)
+	assert.Equal(t, []CheckConstraint{{Name: "positive_quantity", Definition: "CHECK (quantity > 0)"}}, orders.Checks)
+	assert.True(t, orders.Columns["quantity"].Check.MinStrict)
+	assert.Equal(t, Text{}, orders.Columns["note"].DataType)
+
+	// WITHOUT ROWID tables have no rowid alias
+	assert.False(t, tags.Columns["order_id"].IsIdentity)
+	assert.Equal(t, map[string]bool{"order_id": true, "tag": true}, tags.PrimaryKeys)
+	assert.Equal(t, 10, tags.Columns["tag"].Check.MaxLen.OrElse(0))
+}
+
+func TestSQLiteDialect_Insert(t *testing.T) {
+	for _, method := range []LoadMethod{LoadInsert, LoadBatch} {
+		db := openSQLiteTestDB(t)
+		tables, err := SQLiteDialect{}.Introspect(db, SchemaFilter{})
+		assert.NoError(t, err)
+
+		targets := make([]Table, 0)
+		for _, table := range TopologicalSort(tables) {
+			if table.Insertable() {
+				table.RowNum = 20
+				targets = append(targets, table)
+			}
+		}
+		session, err := NewSession(db, false, 0)
+		assert.NoError(t, err)
+		opts := LoadOptions{Dialect: SQLiteDialect{}, Method: method}
+		for _, group := range InsertGroups(targets) {
+			inserted, err := GenerateAndInsertGroup(session, opts, group)
+			assert.NoError(t, err)
+			assert.Equal(t, 20, inserted, method)
+		}
+
+		for _, name := range []string{"customers", "orders", "order_tags"} {
+			var count int
+			assert.NoError(t, db.QueryRow("SELECT count(*) FROM "+name).Scan(&count))
+			assert.Equal(t, 20, count, name)
+		}
+		var violations int
+		assert.NoError(t, db.QueryRow("SELECT count(*) FROM pragma_foreign_key_check").Scan(&violations))
+		assert.Zero(t, violations)
+	}
+}
+
+func TestSQLiteDialect_InsertQuotedNames(t *testing.T) {
+	db := openSQLiteTestDB(t)
+	// reserved words, mixed case, spaces and quotes; "from" and "to" are a cycle filled by UPDATE
+	_, err := db.Exec(`
+		CREATE TABLE "user" (
+			"Id" INTEGER PRIMARY KEY,
+			"order" VARCHAR(20) NOT NULL UNIQUE,
+			"CreatedAt" DATETIME NOT NULL,
+			"full name" TEXT
+		);
+		CREATE TABLE "Order ""Items""" (
+			"select" INTEGER NOT NULL REFERENCES "user" ("Id"),
+			"group" VARCHAR(20) NOT NULL,
+			PRIMARY KEY ("select", "group")
+		) WITHOUT ROWID;
+		CREATE TABLE "from" ("Key" INTEGER PRIMARY KEY, "to" INTEGER REFERENCES "to" ("Key"));
+		CREATE TABLE "to" ("Key" INTEGER PRIMARY KEY, "from" INTEGER NOT NULL REFERENCES "from" ("Key"));
+		INSERT INTO "user" ("Id", "order", "CreatedAt") VALUES (1, 'first', '2024-01-01');
+	`)
+	assert.NoError(t, err)
+	tables, err := SQLiteDialect{}.Introspect(db, SchemaFilter{})
+	assert.NoError(t, err)
+
+	names := map[string]bool{"user": true, `Order "Items"`: true, "from": true, "to": true}
+	targets := make([]Table, 0)
+	for _, table := range TopologicalSort(tables) {
+		if names[table.Name] {
+			table.RowNum = 20
+			targets = append(targets, table)
+		}
+	}
+
+	// "user" is not generated, it already has a row
+	children := make([]Table, 0)
+	for _, table := range targets {
+		if table.Name != "user" {
+			children = append(children, table)
+		}
+	}
+	missing, err := MissingParents(db, SQLiteDialect{}, children)
+	assert.NoError(t, err)
+	assert.Empty(t, missing)
+
+	session, err := NewSession(db, false, 0)
+	assert.NoError(t, err)
+	for _, method := range []LoadMethod{LoadInsert, LoadBatch} {
+		opts := LoadOptions{Dialect: SQLiteDialect{}, Method: method}
+		for _, group := range InsertGroups(targets) {
+			inserted, err := GenerateAndInsertGroup(session, opts, group)
+			assert.NoError(t, err)
+			assert.Equal(t, 20*len(group), inserted, method)
+		}
+	}
+
+	for name, rows := range map[string]int{`"user"`: 41, `"Order ""Items"""`: 40, `"from"`: 40, `"to"`: 40} {
+		var count int
+		assert.NoError(t, db.QueryRow("SELECT count(*) FROM "+name).Scan(&count))
+		assert.Equal(t, rows, count, name)
+	}
+	var unfilled, violations int
+	assert.NoError(t, db.QueryRow(`SELECT count(*) FROM "from" WHERE "to" IS NULL`).Scan(&unfilled))
+	assert.Less(t, unfilled, 40)
+	assert.NoError(t, db.QueryRow("SELECT count(*) FROM pragma_foreign_key_check").Scan(&violations))
+	assert.Zero(t, violations)
+}
@@ -4,259 +4,383 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/victornguen/db-faker/datagen"
+	"path"
+	"sort"
 	"strings"
 )
 
-const (
-	getTableNamesQuery = `
-		SELECT table_name 
-		FROM information_schema.tables 
-		WHERE table_schema = 'public'
-	`
-
-	getPrimaryKeyColumnsQuery = `
-		SELECT kcu.column_name
-		FROM information_schema.key_column_usage kcu
-		JOIN information_schema.table_constraints tc
-			ON tc.constraint_name = kcu.constraint_name
-		WHERE tc.table_name = $1
-			AND tc.constraint_type = 'PRIMARY KEY'
-	`
+// QualifyName joins schema and name into schema.name
+func QualifyName(schema, name string) string {
+	if schema == "" {
+		return name
+	}
+	return schema + "." + name
+}
 
-	getTableDependenciesQuery = `
-		SELECT DISTINCT kcu2.table_name
-		FROM information_schema.referential_constraints rc
-		JOIN information_schema.key_column_usage kcu
-			ON rc.constraint_name = kcu.constraint_name
-		JOIN information_schema.key_column_usage kcu2
-			ON rc.unique_constraint_name = kcu2.constraint_name
-		WHERE kcu.table_name = $1
-	`
+// Match reports whether schema passes the filter
+func (f SchemaFilter) Match(schema string) bool {
+	for _, s := range f.Exclude {
+		if s == schema {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, s := range f.Include {
+		if s == schema {
+			return true
+		}
+	}
+	return false
+}
 
-	getPrimaryKeyColumnQuery = `
-		SELECT kcu.column_name
-		FROM information_schema.key_column_usage kcu
-		JOIN information_schema.table_constraints tc
-			ON tc.constraint_name = kcu.constraint_name
-		WHERE tc.table_name = $1
-			AND tc.constraint_type = 'PRIMARY KEY'
-		LIMIT 1
-	`
-)
+// Match reports whether table passes the filter
+func (f TableFilter) Match(table Table) bool {
+	for _, pattern := range f.Exclude {
+		if matchTable(pattern, table) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if matchTable(pattern, table) {
+			return true
+		}
+	}
+	return false
+}
 
-func ApplyRulesToTables(tables *[]Table, rules datagen.TablesRules) error {
-	for i, table := range *tables {
-		if rule, ok := rules.Rules[table.Name]; ok {
-			table.RowNum = rule.RowNum
-			for colName, rule := range rule.Rules {
-				genFunc, err := datagen.RuleToGeneratorFunc(rule)
-				if err != nil {
-					return fmt.Errorf("error generating function for rule %s: %v", rule, err)
-				}
-				col := table.Columns[colName]
-				col.DataGen = genFunc
-				_, present := table.Columns[colName]
-				if present {
-					table.Columns[colName] = col
-				}
-			}
-			(*tables)[i] = table
+// Validate checks syntax of the patterns
+func (f TableFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid table pattern %s: %v", pattern, err)
 		}
 	}
 	return nil
 }
 
-func GetTablesWithDependencies(db *sql.DB) ([]Table, error) {
-	tables := make([]Table, 0)
+func matchTable(pattern string, table Table) bool {
+	name := table.Name
+	if strings.Contains(pattern, ".") {
+		name = table.QualifiedName()
+	}
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
 
-	// Get all tables
-	rows, err := db.Query(getTableNamesQuery)
-	if err != nil {
+// FilterTables returns tables passing the filter
+func FilterTables(tables []Table, filter TableFilter) ([]Table, error) {
+	if err := filter.Validate(); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
-			return nil, err
-		}
-
-		// Get primary keys
-		pkCols, err := getPrimaryKeyColumns(db, tableName)
-		if err != nil {
-			return nil, err
+	selected := make([]Table, 0, len(tables))
+	for _, table := range tables {
+		if filter.Match(table) {
+			selected = append(selected, table)
 		}
+	}
+	return selected, nil
+}
 
-		// Get columns
-		columns, err := GetColumns(db, tableName)
-		if err != nil {
-			return nil, err
+// externalForeignKeys returns foreign keys of tables with rows to generate which reference tables
+// without rows to generate, keyed by qualified name of the referencing table
+func externalForeignKeys(tables []Table) map[string][]ForeignKey {
+	names := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		if table.RowNum > 0 {
+			names[table.QualifiedName()] = true
 		}
-
-		columnsMap := make(map[string]Column)
-		for _, col := range columns {
-			columnsMap[col.Name] = col
+	}
+	external := make(map[string][]ForeignKey)
+	for _, table := range tables {
+		if table.RowNum == 0 {
+			continue
 		}
-
-		// Get dependencies
-		deps, err := getTableDependencies(db, tableName)
-		if err != nil {
-			return nil, err
+		for _, fk := range table.ForeignKeys {
+			if !names[fk.RefQualifiedName()] {
+				external[table.QualifiedName()] = append(external[table.QualifiedName()], fk)
+			}
 		}
-
-		tables = append(tables, Table{
-			Name:        tableName,
-			Columns:     columnsMap,
-			DependsOn:   deps,
-			PrimaryKeys: pkCols,
-			RowNum:      0,
-			Rules:       make(map[string]func() string),
-		})
 	}
-
-	return tables, nil
+	return external
 }
 
-func getPrimaryKeyColumns(db *sql.DB, tableName string) (map[string]bool, error) {
-	rows, err := db.Query(getPrimaryKeyColumnsQuery, tableName)
-	if err != nil {
-		return nil, err
+// MissingParents checks that tables which are referenced by the generated tables, but excluded or without rows
+// to generate, already have rows to reference. Problems are returned as messages, one per foreign key.
+func MissingParents(db Querier, dialect Dialect, tables []Table) ([]string, error) {
+	external := externalForeignKeys(tables)
+	names := make([]string, 0, len(external))
+	for name := range external {
+		names = append(names, name)
 	}
-	defer rows.Close()
-
-	pkCols := make(map[string]bool)
-	for rows.Next() {
-		var colName string
-		if err := rows.Scan(&colName); err != nil {
-			return nil, err
+	sort.Strings(names)
+
+	hasRows := make(map[string]bool)
+	missing := make([]string, 0)
+	for _, name := range names {
+		for _, fk := range external[name] {
+			ref := fk.RefQualifiedName()
+			found, checked := hasRows[ref]
+			if !checked {
+				query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s)", quoteTable(dialect, fk.RefSchema, fk.RefTable))
+				if err := db.QueryRow(query).Scan(&found); err != nil {
+					return nil, fmt.Errorf("can't read rows of %s referenced by %s: %v", ref, name, err)
+				}
+				hasRows[ref] = found
+			}
+			if !found {
+				missing = append(missing, fmt.Sprintf("%s references %s by %s (%s), the table is not generated and has no rows",
+					name, ref, fk.Name, strings.Join(fk.Columns, ", ")))
+			}
 		}
-		pkCols[colName] = true
 	}
-	return pkCols, nil
+	return missing, nil
 }
 
-func getTableDependencies(db *sql.DB, tableName string) ([]string, error) {
-	rows, err := db.Query(getTableDependenciesQuery, tableName)
-	if err != nil {
-		return nil, err
+// findTableRule looks up rules by qualified table name first, then by bare table name
+func findTableRule(rules datagen.TablesRules, table Table) (datagen.TableRule, bool) {
+	if rule, ok := rules.Rules[table.QualifiedName()]; ok {
+		return rule, true
 	}
-	defer rows.Close()
+	rule, ok := rules.Rules[table.Name]
+	return rule, ok
+}
 
-	deps := make([]string, 0)
-	for rows.Next() {
-		var dep string
-		if err := rows.Scan(&dep); err != nil {
-			return nil, err
+// validateRuleValues checks that values of oneof and constant rules are allowed by the column type
+func validateRuleValues(col Column, rule string) error {
+	enum, ok := col.DataType.(Enum)
+	if !ok {
+		return nil
+	}
+	values, ok := datagen.RuleValues(rule)
+	if !ok {
+		return nil
+	}
+	for _, v := range values {
+		if !enum.HasLabel(v) {
+			return fmt.Errorf("value %q of rule %s is not a label of enum %s (%s)",
+				v, rule, enum.Name, strings.Join(enum.Labels, ", "))
 		}
-		deps = append(deps, dep)
 	}
-
-	return deps, nil
+	return nil
 }
 
-func TopologicalSort(tables []Table) []Table {
-	var sorted []Table
-	visited := make(map[string]bool)
+// applyAttributeRule sets generator of composite column attribute, path looks like column.attribute
+func applyAttributeRule(table Table, path string, genFunc func() string) error {
+	colName, attrPath, _ := strings.Cut(path, ".")
+	col, present := table.Columns[colName]
+	if !present {
+		return nil
+	}
+	composite, ok := col.DataType.(Composite)
+	if !ok {
+		return fmt.Errorf("column %s.%s is not of composite type, rule for %s can't be used",
+			table.QualifiedName(), colName, path)
+	}
+	if !composite.HasAttribute(attrPath) {
+		return fmt.Errorf("type %s of column %s.%s has no attribute %s",
+			composite.Name, table.QualifiedName(), colName, attrPath)
+	}
+	if col.AttributeRules == nil {
+		col.AttributeRules = make(map[string]func() string)
+	}
+	col.AttributeRules[attrPath] = genFunc
+	col.DataGen = composite.Generator(col.AttributeRules)
+	table.Columns[colName] = col
+	return nil
+}
 
-	var visit func(table Table)
-	visit = func(table Table) {
-		if !visited[table.Name] {
-			visited[table.Name] = true
-			for _, dep := range table.DependsOn {
-				for _, t := range tables {
-					if t.Name == dep {
-						visit(t)
+// ApplyRulesToTables sets numbers of rows and column generators of tables from rules.
+// Rules of tables without rows are not applied.
+func ApplyRulesToTables(tables *[]Table, rules datagen.TablesRules) error {
+	if rules.NullPercent < 0 || rules.NullPercent > 100 {
+		return fmt.Errorf("null_percent must be between 0 and 100, got %d", rules.NullPercent)
+	}
+	for i, table := range *tables {
+		for colName, col := range table.Columns {
+			if col.IsNullable {
+				col.NullPercent = rules.NullPercent
+				table.Columns[colName] = col
+			}
+		}
+		if rule, ok := findTableRule(rules, table); ok {
+			if !table.Insertable() && rule.RowNum > 0 {
+				if table.Kind == KindPartition {
+					return fmt.Errorf("%s is a partition, rows are inserted through %s", table.QualifiedName(), table.PartitionOf)
+				}
+				return fmt.Errorf("%s is a %s, rows can't be inserted into it", table.QualifiedName(), table.Kind)
+			}
+			table.RowNum = rule.RowNum
+			if table.RowNum == 0 {
+				// no rows are generated, the table is only referenced
+				(*tables)[i] = table
+				continue
+			}
+			for colName, rule := range rule.Rules {
+				if strings.EqualFold(strings.TrimSpace(rule), datagen.DefaultRule) {
+					col, present := table.Columns[colName]
+					if !present {
+						continue
+					}
+					if !col.HasDefault() && !col.IsNullable {
+						return fmt.Errorf("column %s.%s has no default and is NOT NULL, rule %s can't be used",
+							table.QualifiedName(), colName, rule)
+					}
+					col.UseDefault = true
+					table.Columns[colName] = col
+					continue
+				}
+				genFunc, err := datagen.RuleToGeneratorFunc(rule)
+				if err != nil {
+					return fmt.Errorf("error generating function for rule %s: %v", rule, err)
+				}
+				if _, present := table.Columns[colName]; !present && strings.Contains(colName, ".") {
+					if err := applyAttributeRule(table, colName, genFunc); err != nil {
+						return err
+					}
+					continue
+				}
+				col := table.Columns[colName]
+				if err := validateRuleValues(col, rule); err != nil {
+					return fmt.Errorf("%s.%s: %v", table.QualifiedName(), colName, err)
+				}
+				col.DataGen = col.Check.Filter(genFunc)
+				_, present := table.Columns[colName]
+				if present {
+					table.Columns[colName] = col
+				}
+			}
+			for colName, percent := range rule.Nulls {
+				col, present := table.Columns[colName]
+				if !present {
+					continue
+				}
+				if percent < 0 || percent > 100 {
+					return fmt.Errorf("NULL probability for %s.%s must be between 0 and 100, got %d",
+						table.QualifiedName(), colName, percent)
+				}
+				if !col.IsNullable && percent > 0 {
+					return fmt.Errorf("column %s.%s is NOT NULL, it can't have NULL probability",
+						table.QualifiedName(), colName)
+				}
+				col.NullPercent = percent
+				table.Columns[colName] = col
+			}
+			for colName, col := range table.Columns {
+				if _, ok := col.DataType.(Unsupported); !ok || col.skipInInsert() || col.IsForeignKey {
+					continue
+				}
+				if _, ok := rule.Rules[colName]; ok {
+					continue
+				}
+				switch {
+				case col.HasDefault():
+					col.UseDefault = true
+				case col.IsNullable:
+					col.NullPercent = 100
+				default:
+					return fmt.Errorf("column %s.%s has type %s which can't be generated, set a rule for it",
+						table.QualifiedName(), colName, TypeName(col.DataType))
+				}
+				table.Columns[colName] = col
+			}
+			if len(rule.Partitions) > 0 {
+				colName, genFunc, err := partitionKeyGenerator(table, rule.Partitions)
+				if err != nil {
+					return err
+				}
+				col := table.Columns[colName]
+				col.DataGen = genFunc
+				table.Columns[colName] = col
+			}
+			if rule.Tree != nil {
+				tree, err := newTree(table, *rule.Tree)
+				if err != nil {
+					return err
+				}
+				table.Tree = tree
+				// the tree references only inserted rows, it doesn't need to be filled after insertion
+				for j, fk := range table.ForeignKeys {
+					if isTreeForeignKey(table, fk) {
+						table.ForeignKeys[j].BackFill = false
 					}
 				}
 			}
-			sorted = append(sorted, table)
+			(*tables)[i] = table
 		}
 	}
-
-	for _, table := range tables {
-		visit(table)
-	}
-
-	return sorted
+	return nil
 }
 
-func getPrimaryKeyColumn(db *sql.DB, tableName string) (string, error) {
-	var colName string
-	err := db.QueryRow(getPrimaryKeyColumnQuery, tableName).Scan(&colName)
+// GetTablesWithDependencies reads tables of schemas passing the filter with their columns, types and constraints.
+// The whole catalog is read by a fixed number of queries, whatever the number of tables.
+func GetTablesWithDependencies(db *sql.DB, filter SchemaFilter) ([]Table, error) {
+	catalog, err := loadCatalog(db)
 	if err != nil {
-		return "", fmt.Errorf("error getting primary key for %s: %v", tableName, err)
+		return nil, err
 	}
-	return colName, nil
+	return catalog.tables(filter)
 }
 
-func GenerateAndInsertData(db *sql.DB, table Table) error {
-
-	// Filter out primary key columns
-	var filteredColumns []Column
-	for _, col := range table.Columns {
-		switch col.DataType.(type) {
-		case Serial, BigSerial, SmallSerial, TsVector, TsQuery:
-			continue
-		}
-		if !table.PrimaryKeys[col.Name] {
-			filteredColumns = append(filteredColumns, col)
+// markForeignKeyColumns sets IsForeignKey and reference table on columns used by foreign keys
+func markForeignKeyColumns(columns map[string]Column, fks []ForeignKey) {
+	for _, fk := range fks {
+		for _, colName := range fk.Columns {
+			col, ok := columns[colName]
+			if !ok || col.IsForeignKey {
+				continue
+			}
+			col.IsForeignKey = true
+			col.RefSchema = fk.RefSchema
+			col.RefTable = fk.RefTable
+			columns[colName] = col
 		}
 	}
+}
 
-	// Prepare column names and placeholders for non-PK columns
-	columns := make([]string, 0, len(filteredColumns))
-	placeholders := make([]string, 0, len(filteredColumns))
-	for i, col := range filteredColumns {
-		columns = append(columns, col.Name)
-		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
-	}
-
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-		table.Name,
-		strings.Join(columns, ", "),
-		strings.Join(placeholders, ", "),
-	)
-
-	stmt, err := db.Prepare(query)
-	if err != nil {
-		return err
+// foreignKeyDependencies returns distinct qualified names of tables referenced by fks
+func foreignKeyDependencies(fks []ForeignKey) []string {
+	deps := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, fk := range fks {
+		name := fk.RefQualifiedName()
+		if !seen[name] {
+			seen[name] = true
+			deps = append(deps, name)
+		}
 	}
-	defer stmt.Close()
-
-	for i := 0; i < table.RowNum; i++ {
-		values := make([]interface{}, len(filteredColumns))
-		for j, col := range filteredColumns {
-			if col.IsForeignKey && col.RefTable == "" {
-				return fmt.Errorf("no reference table for %s.%s", table.Name, col.Name)
-			} else if col.IsForeignKey {
-				pkCol, err := getPrimaryKeyColumn(db, col.RefTable)
-				if err != nil {
-					return fmt.Errorf("table %s foreign key error: %v", table.Name, err)
-				}
-
-				var refID interface{}
-				// Get random ID from referenced table
-				err = db.QueryRow(
-					fmt.Sprintf("SELECT %s FROM %s ORDER BY RANDOM() LIMIT 1", pkCol, col.RefTable),
-				).Scan(&refID)
+	return deps
+}
 
-				if err != nil {
-					return fmt.Errorf("no reference data found in %s for %s.%s: %v",
-						col.RefTable, table.Name, col.Name, err)
-				}
-				values[j] = refID
-			} else {
-				values[j] = col.DataGen()
-			}
+// completeTable derives generators, foreign key columns, dependencies and CHECK restrictions
+// from columns and constraints of the table
+func completeTable(table *Table) {
+	for name, col := range table.Columns {
+		col.DataGen = col.DataType.DefaultGenerator()
+		if domain, ok := col.DataType.(Domain); ok {
+			col.Check = domain.Check
 		}
+		table.Columns[name] = col
+	}
+	markForeignKeyColumns(table.Columns, table.ForeignKeys)
+	table.DependsOn = foreignKeyDependencies(table.ForeignKeys)
+	table.Warnings = applyCheckConstraints(table)
+	table.Warnings = append(table.Warnings, unsupportedColumns(*table)...)
+}
 
-		_, err := stmt.Exec(values...)
-		if err != nil {
-			fmt.Printf("Error inserting row %d into %s: %v\n", i, table.Name, err)
+// unsupportedColumns returns warnings about columns of types without a generator
+func unsupportedColumns(table Table) []string {
+	warnings := make([]string, 0)
+	for _, col := range table.Columns {
+		if t, ok := col.DataType.(Unsupported); ok {
+			warnings = append(warnings, fmt.Sprintf("%s.%s: type %s is not supported, the column is written only by a rule",
+				table.QualifiedName(), col.Name, t.Name))
 		}
 	}
-
-	fmt.Printf("Inserted %d rows into %s\n", table.RowNum, table.Name)
-	return nil
+	sort.Strings(warnings)
+	return warnings
 }
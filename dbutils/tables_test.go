@@ -0,0 +1,226 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/victornguen/db-faker/datagen"
+	"testing"
+)
+
+func TestSchemaFilter_Match(t *testing.T) {
+	all := SchemaFilter{}
+	assert.True(t, all.Match("public"))
+	assert.True(t, all.Match("billing"))
+
+	only := SchemaFilter{Include: []string{"billing", "auth"}, Exclude: []string{"auth"}}
+	assert.True(t, only.Match("billing"))
+	assert.False(t, only.Match("auth"))
+	assert.False(t, only.Match("public"))
+}
+
+func TestTopologicalSort_CrossSchema(t *testing.T) {
+	tables := []Table{
+		{Schema: "billing", Name: "invoices", DependsOn: []string{"auth.users", "catalog.items"}},
+		{Schema: "catalog", Name: "items"},
+		{Schema: "auth", Name: "users"},
+		{Schema: "billing", Name: "users"},
+	}
+	sorted := TopologicalSort(tables)
+
+	pos := make(map[string]int)
+	for i, table := range sorted {
+		pos[table.QualifiedName()] = i
+	}
+	assert.Len(t, sorted, 4)
+	assert.Less(t, pos["auth.users"], pos["billing.invoices"])
+	assert.Less(t, pos["catalog.items"], pos["billing.invoices"])
+}
+
+func TestMarkForeignKeyColumns_Composite(t *testing.T) {
+	columns := map[string]Column{
+		"tenant_id":  {Name: "tenant_id"},
+		"account_id": {Name: "account_id"},
+		"amount":     {Name: "amount"},
+	}
+	fks := []ForeignKey{
+		{
+			Name:       "payments_account_fk",
+			Columns:    []string{"tenant_id", "account_id"},
+			RefSchema:  "billing",
+			RefTable:   "accounts",
+			RefColumns: []string{"tenant_id", "id"},
+		},
+		{
+			Name:       "payments_tenant_fk",
+			Columns:    []string{"tenant_id"},
+			RefSchema:  "auth",
+			RefTable:   "tenants",
+			RefColumns: []string{"id"},
+		},
+	}
+	markForeignKeyColumns(columns, fks)
+
+	assert.True(t, columns["tenant_id"].IsForeignKey)
+	assert.Equal(t, "accounts", columns["tenant_id"].RefTable)
+	assert.True(t, columns["account_id"].IsForeignKey)
+	assert.False(t, columns["amount"].IsForeignKey)
+	assert.Equal(t, []string{"billing.accounts", "auth.tenants"}, foreignKeyDependencies(fks))
+}
+
+func TestApplyRulesToTables_Nulls(t *testing.T) {
+	tables := []Table{{
+		Schema: "public",
+		Name:   "users",
+		Columns: map[string]Column{
+			"email":    {Name: "email"},
+			"phone":    {Name: "phone", IsNullable: true},
+			"nickname": {Name: "nickname", IsNullable: true},
+		},
+	}}
+	rules := datagen.TablesRules{
+		NullPercent: 10,
+		Rules: map[string]datagen.TableRule{
+			"users": {RowNum: 5, Nulls: map[string]int{"phone": 30}},
+		},
+	}
+	err := ApplyRulesToTables(&tables, rules)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, tables[0].Columns["email"].NullPercent)
+	assert.Equal(t, 30, tables[0].Columns["phone"].NullPercent)
+	assert.Equal(t, 10, tables[0].Columns["nickname"].NullPercent)
+
+	rules.Rules["users"] = datagen.TableRule{RowNum: 1, Nulls: map[string]int{"email": 5}}
+	assert.Error(t, ApplyRulesToTables(&tables, rules))
+}
+
+func TestApplyRulesToTables_Default(t *testing.T) {
+	tables := []Table{{
+		Schema: "public",
+		Name:   "orders",
+		Columns: map[string]Column{
+			"order_id":   {Name: "order_id", DataType: Int{}, Default: "nextval('orders_order_id_seq'::regclass)"},
+			"status":     {Name: "status", DataType: VarChar{}, Default: "'pending'::character varying"},
+			"total":      {Name: "total", DataType: Numeric{}, IsGenerated: true},
+			"ref":        {Name: "ref", DataType: BigInt{}, IsIdentity: true, IdentityGeneration: "ALWAYS"},
+			"order_date": {Name: "order_date", DataType: Date{}},
+		},
+	}}
+	rules := datagen.TablesRules{Rules: map[string]datagen.TableRule{
+		"orders": {RowNum: 1, Rules: map[string]string{"status": "default"}},
+	}}
+	assert.NoError(t, ApplyRulesToTables(&tables, rules))
+
+	columns := tables[0].Columns
+	assert.True(t, columns["order_id"].skipInInsert())
+	assert.True(t, columns["status"].skipInInsert())
+	assert.True(t, columns["total"].skipInInsert())
+	assert.True(t, columns["ref"].skipInInsert())
+	assert.False(t, columns["order_date"].skipInInsert())
+
+	rules.Rules["orders"] = datagen.TableRule{RowNum: 1, Rules: map[string]string{"order_date": "default"}}
+	assert.Error(t, ApplyRulesToTables(&tables, rules))
+}
+
+func TestApplyRulesToTables_NoRows(t *testing.T) {
+	tables := []Table{{
+		Schema:  "public",
+		Name:    "users",
+		Columns: map[string]Column{"email": {Name: "email", DataType: Text{}}},
+	}}
+	// users are only referenced, their rules are not applied
+	rules := datagen.TablesRules{Rules: map[string]datagen.TableRule{
+		"users": {Rules: map[string]string{"email": "unknown"}},
+	}}
+	assert.NoError(t, ApplyRulesToTables(&tables, rules))
+	assert.Equal(t, 0, tables[0].RowNum)
+	assert.Nil(t, tables[0].Columns["email"].DataGen)
+}
+
+func TestApplyRulesToTables_Enum(t *testing.T) {
+	status := Enum{Name: "public.order_status", Labels: []string{"new", "paid", "shipped"}}
+	tables := []Table{{
+		Schema:  "public",
+		Name:    "orders",
+		Columns: map[string]Column{"status": {Name: "status", DataType: status, DataGen: status.DefaultGenerator()}},
+	}}
+	assert.Contains(t, status.Labels, tables[0].Columns["status"].DataGen())
+
+	rules := datagen.TablesRules{Rules: map[string]datagen.TableRule{
+		"orders": {RowNum: 1, Rules: map[string]string{"status": "oneof[new%20, paid%80]"}},
+	}}
+	assert.NoError(t, ApplyRulesToTables(&tables, rules))
+	assert.Contains(t, []string{"new", "paid"}, tables[0].Columns["status"].DataGen())
+
+	rules.Rules["orders"] = datagen.TableRule{RowNum: 1, Rules: map[string]string{"status": "oneof[new%20, lost%80]"}}
+	assert.Error(t, ApplyRulesToTables(&tables, rules))
+}
+
+func TestApplyRulesToTables_CompositeAttribute(t *testing.T) {
+	address := Composite{
+		Name:       "public.address_t",
+		Attributes: []CompositeAttribute{{Name: "street", DataType: Text{}}, {Name: "city", DataType: Text{}}},
+	}
+	tables := []Table{{
+		Schema: "public",
+		Name:   "users",
+		Columns: map[string]Column{
+			"home_address": {Name: "home_address", DataType: address, DataGen: address.DefaultGenerator()},
+			"email":        {Name: "email", DataType: Text{}},
+		},
+	}}
+	rules := datagen.TablesRules{Rules: map[string]datagen.TableRule{
+		"users": {RowNum: 1, Rules: map[string]string{"home_address.city": "constant[Paris]"}},
+	}}
+	assert.NoError(t, ApplyRulesToTables(&tables, rules))
+	assert.Contains(t, tables[0].Columns["home_address"].DataGen(), `"Paris")`)
+
+	rules.Rules["users"] = datagen.TableRule{RowNum: 1, Rules: map[string]string{"home_address.zip": "postalcode"}}
+	assert.Error(t, ApplyRulesToTables(&tables, rules))
+	rules.Rules["users"] = datagen.TableRule{RowNum: 1, Rules: map[string]string{"email.domain": "url"}}
+	assert.Error(t, ApplyRulesToTables(&tables, rules))
+}
+
+func TestFilterTables(t *testing.T) {
+	tables := []Table{
+		{Schema: "public", Name: "users"},
+		{Schema: "public", Name: "audit_log"},
+		{Schema: "billing", Name: "audit_invoices"},
+		{Schema: "billing", Name: "invoices"},
+	}
+	names := func(filter TableFilter) []string {
+		selected, err := FilterTables(tables, filter)
+		assert.NoError(t, err)
+		result := make([]string, 0)
+		for _, table := range selected {
+			result = append(result, table.QualifiedName())
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"public.users", "billing.invoices"}, names(TableFilter{Exclude: []string{"audit_*"}}))
+	assert.Equal(t, []string{"billing.audit_invoices", "billing.invoices"}, names(TableFilter{Include: []string{"billing.*"}}))
+	assert.Equal(t, []string{"public.users"}, names(TableFilter{Include: []string{"users", "billing.*"}, Exclude: []string{"billing.*"}}))
+
+	_, err := FilterTables(tables, TableFilter{Include: []string{"[users"}})
+	assert.ErrorContains(t, err, "invalid table pattern")
+}
+
+func TestExternalForeignKeys(t *testing.T) {
+	tables := []Table{
+		{Schema: "public", Name: "accounts", RowNum: 0},
+		{Schema: "public", Name: "orders", RowNum: 10, ForeignKeys: []ForeignKey{
+			{Name: "orders_account_fk", Columns: []string{"account_id"}, RefSchema: "public", RefTable: "accounts"},
+			{Name: "orders_user_fk", Columns: []string{"user_id"}, RefSchema: "public", RefTable: "users"},
+			{Name: "orders_parent_fk", Columns: []string{"parent_id"}, RefSchema: "public", RefTable: "orders"},
+		}},
+		{Schema: "public", Name: "notes", RowNum: 0, ForeignKeys: []ForeignKey{
+			{Name: "notes_user_fk", Columns: []string{"user_id"}, RefSchema: "public", RefTable: "users"},
+		}},
+	}
+	external := externalForeignKeys(tables)
+	assert.Len(t, external, 1)
+	fkNames := make([]string, 0)
+	for _, fk := range external["public.orders"] {
+		fkNames = append(fkNames, fk.Name)
+	}
+	assert.Equal(t, []string{"orders_account_fk", "orders_user_fk"}, fkNames)
+}
@@ -0,0 +1,311 @@
+package dbutils
+
+import (
+	"fmt"
+	"github.com/victornguen/db-faker/datagen"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// distributions of children in generated trees
+const (
+	FanoutUniform      = "uniform"      // every row which may have children is an equally likely parent
+	FanoutPreferential = "preferential" // the more children a row has, the more likely it gets another one
+	FanoutFixed        = "fixed"        // rows get FanoutN children each, level by level
+)
+
+const defaultPathSeparator = "/"
+
+var fixedFanoutPattern = regexp.MustCompile(`(?i)^fixed\s*\(\s*(\d+)\s*\)$`)
+
+// Tree - settings of hierarchy generation for a self-referencing foreign key
+type Tree struct {
+	ForeignKey    ForeignKey
+	Roots         int // number of rows without parent
+	MaxDepth      int // maximum depth of a row, roots have depth 0, 0 means unlimited
+	Fanout        string
+	FanoutN       int // number of children of every row for fixed fanout
+	PathColumn    string
+	PathSeparator string
+	DepthColumn   string
+}
+
+// newTree validates tree rule of the table
+func newTree(table Table, rule datagen.TreeRule) (*Tree, error) {
+	fk, err := findSelfReference(table, rule.ForeignKey)
+	if err != nil {
+		return nil, err
+	}
+	if !foreignKeyNullable(table, fk) {
+		return nil, fmt.Errorf("foreign key %s of %s is NOT NULL, tree roots can't be inserted",
+			fk.Name, table.QualifiedName())
+	}
+
+	tree := &Tree{
+		ForeignKey:    fk,
+		Roots:         rule.Roots,
+		MaxDepth:      rule.MaxDepth,
+		PathColumn:    rule.PathColumn,
+		PathSeparator: rule.PathSeparator,
+		DepthColumn:   rule.DepthColumn,
+	}
+	if tree.Roots == 0 {
+		tree.Roots = 1
+	}
+	if tree.Roots < 0 || tree.MaxDepth < 0 {
+		return nil, fmt.Errorf("tree of %s: roots and max_depth can't be negative", table.QualifiedName())
+	}
+	if tree.PathSeparator == "" {
+		tree.PathSeparator = defaultPathSeparator
+	}
+
+	fanout := strings.ToLower(strings.TrimSpace(rule.Fanout))
+	switch {
+	case fanout == "" || fanout == FanoutUniform:
+		tree.Fanout = FanoutUniform
+	case fanout == FanoutPreferential:
+		tree.Fanout = FanoutPreferential
+	case fixedFanoutPattern.MatchString(fanout):
+		tree.Fanout = FanoutFixed
+		tree.FanoutN, _ = strconv.Atoi(fixedFanoutPattern.FindStringSubmatch(fanout)[1])
+		if tree.FanoutN == 0 {
+			return nil, fmt.Errorf("tree of %s: fixed fanout must be positive", table.QualifiedName())
+		}
+	default:
+		return nil, fmt.Errorf("tree of %s: unknown fanout %s, expected %s, %s or %s(n)",
+			table.QualifiedName(), rule.Fanout, FanoutUniform, FanoutPreferential, FanoutFixed)
+	}
+
+	for _, colName := range []string{tree.PathColumn, tree.DepthColumn} {
+		if colName == "" {
+			continue
+		}
+		col, present := table.Columns[colName]
+		if !present {
+			return nil, fmt.Errorf("tree of %s: column %s not found", table.QualifiedName(), colName)
+		}
+		if col.skipInInsert() || col.IsForeignKey {
+			return nil, fmt.Errorf("tree of %s: column %s can't be written", table.QualifiedName(), colName)
+		}
+	}
+	return tree, nil
+}
+
+// findSelfReference returns foreign key of the table referencing the table itself.
+// name is a constraint or column name, it may be empty if there is only one such foreign key.
+func findSelfReference(table Table, name string) (ForeignKey, error) {
+	found := make([]ForeignKey, 0)
+	for _, fk := range table.ForeignKeys {
+		if fk.RefQualifiedName() != table.QualifiedName() {
+			continue
+		}
+		if name == "" || fk.Name == name || (len(fk.Columns) == 1 && fk.Columns[0] == name) {
+			found = append(found, fk)
+		}
+	}
+	switch {
+	case len(found) == 1:
+		return found[0], nil
+	case len(found) == 0 && name == "":
+		return ForeignKey{}, fmt.Errorf("table %s has no self-referencing foreign key for tree", table.QualifiedName())
+	case len(found) == 0:
+		return ForeignKey{}, fmt.Errorf("table %s has no self-referencing foreign key %s", table.QualifiedName(), name)
+	default:
+		return ForeignKey{}, fmt.Errorf("table %s has several self-referencing foreign keys, set foreign_key of tree",
+			table.QualifiedName())
+	}
+}
+
+// shape generates parents of n rows, parents[i] < i, -1 for roots
+func (t Tree) shape(n int) ([]int, error) {
+	parents := make([]int, n)
+	depths := make([]int, n)
+	children := make([]int, n)
+	// rows which can have children; for preferential fanout a row appears once more for each child
+	candidates := make([]int, 0, n)
+	canHaveChildren := func(i int) bool {
+		return t.MaxDepth == 0 || depths[i] < t.MaxDepth
+	}
+
+	for i := 0; i < n; i++ {
+		if i < t.Roots {
+			parents[i] = -1
+			if canHaveChildren(i) {
+				candidates = append(candidates, i)
+			}
+			continue
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("tree with %d roots, max depth %d and fanout %s(%d) can't hold %d rows",
+				t.Roots, t.MaxDepth, t.Fanout, t.FanoutN, n)
+		}
+
+		var parent int
+		switch t.Fanout {
+		case FanoutFixed:
+			parent = candidates[0]
+		default:
+			parent = candidates[rand.Intn(len(candidates))]
+		}
+		parents[i] = parent
+		depths[i] = depths[parent] + 1
+		children[parent]++
+
+		if t.Fanout == FanoutFixed && children[parent] == t.FanoutN {
+			candidates = candidates[1:]
+		}
+		if t.Fanout == FanoutPreferential {
+			candidates = append(candidates, parent)
+		}
+		if canHaveChildren(i) {
+			candidates = append(candidates, i)
+		}
+	}
+	return parents, nil
+}
+
+// treeState - progress of tree insertion
+type treeState struct {
+	tree       *Tree
+	parents    []int
+	refs       [][]interface{} // values of referenced columns of inserted rows, nil if insertion failed
+	depths     []int
+	paths      []string
+	keyColumns []int // indexes of referenced columns among inserted columns, nil if the database fills them
+	pathColumn int   // index of the path column among inserted columns
+}
+
+// newTreeState generates the shape of a tree of n rows inserted into columns
+func newTreeState(tree *Tree, n int, columns []Column) (*treeState, error) {
+	parents, err := tree.shape(n)
+	if err != nil {
+		return nil, err
+	}
+	keyColumns := make([]int, len(tree.ForeignKey.RefColumns))
+	for j, colName := range tree.ForeignKey.RefColumns {
+		if keyColumns[j] = columnIndex(columns, colName); keyColumns[j] < 0 {
+			keyColumns = nil
+			break
+		}
+	}
+	return &treeState{
+		tree:       tree,
+		parents:    parents,
+		refs:       make([][]interface{}, n),
+		depths:     make([]int, n),
+		paths:      make([]string, n),
+		keyColumns: keyColumns,
+		pathColumn: columnIndex(columns, tree.PathColumn),
+	}, nil
+}
+
+// keyed reports whether paths are written by the INSERT: keys of rows are generated with them
+func (s *treeState) keyed() bool {
+	return s.tree.PathColumn != "" && s.pathColumn >= 0 && s.keyColumns != nil
+}
+
+// fullPath returns the path of row i with the given values of referenced columns
+func (s *treeState) fullPath(i int, refs []interface{}) string {
+	parts := make([]string, len(refs))
+	for j, v := range refs {
+		parts[j] = valueString(v)
+	}
+	path := strings.Join(parts, ",")
+	if p := s.parent(i); p >= 0 {
+		path = s.paths[p] + s.tree.PathSeparator + path
+	}
+	return path
+}
+
+// parent returns the nearest inserted ancestor of row i, -1 if there is none.
+// Children of a row which failed to insert are attached to its parent.
+func (s *treeState) parent(i int) int {
+	p := s.parents[i]
+	for p >= 0 && s.refs[p] == nil {
+		p = s.parents[p]
+	}
+	return p
+}
+
+// values returns values of the foreign key, depth and path columns for row i.
+// The row key isn't generated yet, so the path ends with a placeholder replaced by generated,
+// or by inserted if the key is filled by the database.
+func (s *treeState) values(i int) map[string]interface{} {
+	values := make(map[string]interface{})
+	p := s.parent(i)
+	for j, colName := range s.tree.ForeignKey.Columns {
+		if p < 0 {
+			values[colName] = nil
+		} else {
+			values[colName] = s.refs[p][j]
+		}
+	}
+	s.depths[i] = 0
+	if p >= 0 {
+		s.depths[i] = s.depths[p] + 1
+	}
+	if s.tree.DepthColumn != "" {
+		values[s.tree.DepthColumn] = s.depths[i]
+	}
+	if s.tree.PathColumn != "" {
+		// placeholder unique among siblings
+		path := fmt.Sprintf("pending%d", i)
+		if p >= 0 {
+			path = s.paths[p] + s.tree.PathSeparator + path
+		}
+		values[s.tree.PathColumn] = path
+	}
+	return values
+}
+
+// generated replaces the placeholder of the path in values of row i by its full path
+// when the row key is among the values, then no UPDATE is needed after insertion
+func (s *treeState) generated(i int, values []interface{}) {
+	if !s.keyed() {
+		return
+	}
+	refs := make([]interface{}, len(s.keyColumns))
+	for j, k := range s.keyColumns {
+		refs[j] = values[k]
+	}
+	s.paths[i] = s.fullPath(i, refs)
+	values[s.pathColumn] = s.paths[i]
+}
+
+// remember remembers values of referenced columns of row i, it reports whether its full path
+// must be written after insertion
+func (s *treeState) remember(i int, refs []interface{}) bool {
+	s.refs[i] = refs
+	if s.tree.PathColumn == "" || s.keyed() {
+		return false
+	}
+	s.paths[i] = s.fullPath(i, refs)
+	return true
+}
+
+// inserted remembers values of referenced columns of inserted row i.
+// If the key is filled by the database, the row was inserted with a placeholder path and its full path is
+// written by an UPDATE, so the path column must accept the placeholder, e.g. no CHECK on its format.
+func (s *treeState) inserted(db Querier, dialect Dialect, table Table, i int, refs []interface{}) error {
+	if !s.remember(i, refs) {
+		return nil
+	}
+
+	args := []interface{}{s.paths[i]}
+	conditions := make([]string, 0, len(refs))
+	for j, colName := range s.tree.ForeignKey.RefColumns {
+		args = append(args, refs[j])
+		conditions = append(conditions, fmt.Sprintf("%s = %s", dialect.QuoteIdent(colName), dialect.Placeholder(len(args))))
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s", quoteTable(dialect, table.Schema, table.Name),
+		dialect.QuoteIdent(s.tree.PathColumn), dialect.Placeholder(1), strings.Join(conditions, " AND "))
+	_, err := db.Exec(query, args...)
+	return err
+}
+
+// isTreeForeignKey reports whether the foreign key is generated by tree mode of the table
+func isTreeForeignKey(table Table, fk ForeignKey) bool {
+	return table.Tree != nil && table.Tree.ForeignKey.Name == fk.Name
+}
@@ -0,0 +1,146 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/victornguen/db-faker/datagen"
+	"testing"
+)
+
+func TestTree_Shape(t *testing.T) {
+	for _, fanout := range []string{FanoutUniform, FanoutPreferential} {
+		tree := Tree{Roots: 3, MaxDepth: 2, Fanout: fanout}
+		parents, err := tree.shape(100)
+		assert.NoError(t, err)
+
+		roots := 0
+		for i, p := range parents {
+			if p < 0 {
+				roots++
+				continue
+			}
+			// parents come first, so there are no cycles
+			assert.Less(t, p, i)
+			depth := 0
+			for ; p >= 0; p = parents[p] {
+				depth++
+			}
+			assert.LessOrEqual(t, depth, 2)
+		}
+		assert.Equal(t, 3, roots)
+	}
+
+	fixed := Tree{Roots: 1, Fanout: FanoutFixed, FanoutN: 2}
+	parents, err := fixed.shape(7)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{-1, 0, 0, 1, 1, 2, 2}, parents)
+
+	fixed.MaxDepth = 1
+	_, err = fixed.shape(7)
+	assert.Error(t, err)
+}
+
+func TestTreeState_Values(t *testing.T) {
+	tree := &Tree{
+		ForeignKey:    ForeignKey{Name: "parent_fk", Columns: []string{"parent_id"}, RefColumns: []string{"id"}},
+		Roots:         1,
+		Fanout:        FanoutFixed,
+		FanoutN:       1,
+		PathColumn:    "path",
+		PathSeparator: "/",
+		DepthColumn:   "depth",
+	}
+	// id is filled by the database, paths end with a placeholder until the row is inserted
+	state, err := newTreeState(tree, 3, []Column{{Name: "depth"}, {Name: "parent_id"}, {Name: "path"}})
+	assert.NoError(t, err)
+
+	root := state.values(0)
+	assert.Nil(t, root["parent_id"])
+	assert.Equal(t, 0, root["depth"])
+	state.refs[0] = []interface{}{int64(10)}
+	state.paths[0] = "10"
+
+	child := state.values(1)
+	assert.Equal(t, int64(10), child["parent_id"])
+	assert.Equal(t, 1, child["depth"])
+	assert.Equal(t, "10/pending1", child["path"])
+
+	// row 1 failed to insert, its child is attached to the root
+	grandchild := state.values(2)
+	assert.Equal(t, int64(10), grandchild["parent_id"])
+	assert.Equal(t, 1, grandchild["depth"])
+}
+
+func TestTreeState_GeneratedKey(t *testing.T) {
+	tree := &Tree{
+		ForeignKey:    ForeignKey{Name: "parent_fk", Columns: []string{"parent_id"}, RefColumns: []string{"code"}},
+		Roots:         1,
+		Fanout:        FanoutFixed,
+		FanoutN:       1,
+		PathColumn:    "path",
+		PathSeparator: ".",
+	}
+	columns := []Column{{Name: "code"}, {Name: "parent_id"}, {Name: "path"}}
+	state, err := newTreeState(tree, 2, columns)
+	assert.NoError(t, err)
+	assert.True(t, state.keyed())
+
+	root := []interface{}{"a", nil, state.values(0)["path"]}
+	state.generated(0, root)
+	assert.Equal(t, "a", root[2])
+	// the path is written by the INSERT, nothing is updated
+	assert.NoError(t, state.inserted(nil, nil, Table{}, 0, []interface{}{"a"}))
+
+	child := []interface{}{"b", "a", state.values(1)["path"]}
+	assert.Equal(t, "a.pending1", child[2])
+	state.generated(1, child)
+	assert.Equal(t, "a.b", child[2])
+}
+
+func TestGenerateUniqueRow_TreePath(t *testing.T) {
+	fk := ForeignKey{Name: "parent_fk", Columns: []string{"parent_id"}, RefTable: "nodes", RefColumns: []string{"code"}}
+	tree := &Tree{ForeignKey: fk, Roots: 2, Fanout: FanoutUniform, PathColumn: "path", PathSeparator: "/"}
+	table := Table{
+		Name: "nodes",
+		Columns: map[string]Column{
+			"code":      {Name: "code", DataGen: func() string { return "a" }},
+			"parent_id": {Name: "parent_id", IsNullable: true, IsForeignKey: true},
+			"path":      {Name: "path"},
+		},
+		ForeignKeys: []ForeignKey{fk},
+		Uniques:     []UniqueConstraint{{Name: "nodes_path_key", Columns: []string{"path"}}},
+		Tree:        tree,
+		RowNum:      2,
+	}
+	columns := insertColumns(table)
+	tracker, err := newUniqueTracker(nil, nil, table, columns)
+	assert.NoError(t, err)
+	state, err := newTreeState(tree, 2, columns)
+	assert.NoError(t, err)
+
+	values, err := generateUniqueRow(nil, tracker, table, columns, state, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", values[columnIndex(columns, "path")])
+	// the second root gets the same path "a", its placeholder pending1 would be unique
+	_, err = generateUniqueRow(nil, tracker, table, columns, state, 1)
+	assert.ErrorContains(t, err, "path")
+}
+
+func TestApplyRulesToTables_Tree(t *testing.T) {
+	employees := cycleTable("employees", "employees", true, false)
+	employees.Columns["depth"] = Column{Name: "depth"}
+	tables := TopologicalSort([]Table{employees})
+	rules := datagen.TablesRules{Rules: map[string]datagen.TableRule{
+		"employees": {RowNum: 10, Tree: &datagen.TreeRule{Roots: 2, Fanout: "fixed(3)", DepthColumn: "depth"}},
+	}}
+	assert.NoError(t, ApplyRulesToTables(&tables, rules))
+
+	tree := tables[0].Tree
+	assert.NotNil(t, tree)
+	assert.Equal(t, "employees_ref_fk", tree.ForeignKey.Name)
+	assert.Equal(t, 3, tree.FanoutN)
+	assert.False(t, tables[0].ForeignKeys[0].BackFill)
+	assert.Empty(t, FindCycles(tables))
+
+	rules.Rules["employees"] = datagen.TableRule{RowNum: 10, Tree: &datagen.TreeRule{Fanout: "random"}}
+	assert.Error(t, ApplyRulesToTables(&tables, rules))
+}
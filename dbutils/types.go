@@ -0,0 +1,63 @@
+package dbutils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resolveType converts type from the catalog snapshot to DataType.
+// formatted is the type name with modifiers, e.g. character varying(50), used for base types.
+func (s *catalogSnapshot) resolveType(oid uint32, formatted string) (DataType, error) {
+	t, ok := s.types[oid]
+	if !ok {
+		return StringToDataType(formatted)
+	}
+	switch {
+	case t.category == "A":
+		return s.arrayType(t, formatted)
+	case t.kind == "e":
+		return Enum{Name: QualifyName(t.schema, t.name), Labels: s.enumLabels[oid]}, nil
+	case t.kind == "d":
+		return s.domainType(t)
+	case t.kind == "c":
+		return s.compositeType(t)
+	case t.kind == "p" || t.kind == "r" || t.kind == "m":
+		return nil, fmt.Errorf("unsupported user-defined type: %s", QualifyName(t.schema, t.name))
+	default:
+		return StringToDataType(formatted)
+	}
+}
+
+// arrayType resolves type of array by its element type, modifiers of the array apply to its elements
+func (s *catalogSnapshot) arrayType(t catalogType, formatted string) (DataType, error) {
+	elemFormatted, ok := strings.CutSuffix(formatted, "[]")
+	if !ok {
+		elemFormatted = s.types[t.elem].formatted
+	}
+	elem, err := s.resolveType(t.elem, elemFormatted)
+	if err != nil {
+		return nil, err
+	}
+	return Array{Elem: elem}, nil
+}
+
+// domainType resolves domain to its base type and parses its CHECK constraints
+func (s *catalogSnapshot) domainType(t catalogType) (DataType, error) {
+	base, err := s.resolveType(t.base, t.baseFormatted)
+	if err != nil {
+		return nil, err
+	}
+	return NewDomain(QualifyName(t.schema, t.name), base, s.domains[t.oid]), nil
+}
+
+func (s *catalogSnapshot) compositeType(t catalogType) (DataType, error) {
+	composite := Composite{Name: QualifyName(t.schema, t.name)}
+	for _, attr := range s.columns[t.relid] {
+		attrType, err := s.resolveType(attr.typeOid, attr.formatted)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s of %s: %v", attr.name, composite.Name, err)
+		}
+		composite.Attributes = append(composite.Attributes, CompositeAttribute{Name: attr.name, DataType: attrType})
+	}
+	return composite, nil
+}
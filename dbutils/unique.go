@@ -0,0 +1,178 @@
+package dbutils
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// uniqueRetryLimit - how many times a row is regenerated to get unique values
+const uniqueRetryLimit = 1000
+
+// UniqueConstraint - UNIQUE constraint, primary key or unique index of a table
+type UniqueConstraint struct {
+	Name      string   `json:"name" yaml:"name"`
+	Columns   []string `json:"columns" yaml:"columns"`
+	Predicate string   `json:"predicate,omitempty" yaml:"predicate,omitempty"` // WHERE clause of a partial unique index, empty otherwise
+}
+
+// uniqueKey tracks values of one unique constraint
+type uniqueKey struct {
+	constraint UniqueConstraint
+	positions  []int               // positions of constraint columns in a generated row
+	predicate  map[int]ColumnCheck // parsed predicate of a partial index by row position, nil if every row is tracked
+	seen       map[string]bool
+}
+
+// uniqueTracker rejects generated rows which would violate unique constraints,
+// it is shared by workers loading parts of one table
+type uniqueTracker struct {
+	mu   sync.Mutex
+	keys []*uniqueKey
+}
+
+// newUniqueTracker prepares tracking of unique constraints whose columns are all generated
+// and loads values already stored in the table
+func newUniqueTracker(db Querier, dialect Dialect, table Table, columns []Column) (*uniqueTracker, error) {
+	positions := make(map[string]int, len(columns))
+	for i, col := range columns {
+		positions[col.Name] = i
+	}
+
+	tracker := &uniqueTracker{}
+	for _, u := range table.Uniques {
+		key := &uniqueKey{constraint: u, seen: make(map[string]bool)}
+		generated := true
+		for _, colName := range u.Columns {
+			pos, ok := positions[colName]
+			if !ok {
+				// filled by the database, e.g. serial primary key
+				generated = false
+				break
+			}
+			key.positions = append(key.positions, pos)
+		}
+		if !generated {
+			continue
+		}
+		key.predicate = parsePartialPredicate(u.Predicate, positions)
+		if db != nil {
+			if err := key.loadExisting(db, dialect, table); err != nil {
+				return nil, fmt.Errorf("error loading values of %s: %v", u.Name, err)
+			}
+		}
+		tracker.keys = append(tracker.keys, key)
+	}
+	return tracker, nil
+}
+
+// parsePartialPredicate returns row filter for a partial index predicate.
+// If predicate can't be fully parsed nil is returned, so all rows are tracked, which is stricter than needed.
+func parsePartialPredicate(predicate string, positions map[string]int) map[int]ColumnCheck {
+	if predicate == "" {
+		return nil
+	}
+	checks, unparsed := ParseCheckConstraint(predicate)
+	if len(unparsed) > 0 || len(checks) == 0 {
+		return nil
+	}
+	filter := make(map[int]ColumnCheck, len(checks))
+	for colName, check := range checks {
+		pos, ok := positions[colName]
+		if !ok {
+			return nil
+		}
+		filter[pos] = check
+	}
+	return filter
+}
+
+func (k *uniqueKey) loadExisting(db Querier, dialect Dialect, table Table) error {
+	columns := quoteIdents(dialect, k.constraint.Columns)
+	conditions := make([]string, 0, len(columns)+1)
+	for _, colName := range columns {
+		conditions = append(conditions, colName+" IS NOT NULL")
+	}
+	if k.constraint.Predicate != "" {
+		conditions = append(conditions, "("+k.constraint.Predicate+")")
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(columns, ", "),
+		quoteTable(dialect, table.Schema, table.Name), strings.Join(conditions, " AND "))
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := make([]sql.NullString, len(k.constraint.Columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = v.String
+		}
+		k.seen[strings.Join(parts, "\x00")] = true
+	}
+	return rows.Err()
+}
+
+// rowKey returns key of the row values, false if the row is not covered by the constraint
+func (k *uniqueKey) rowKey(row []interface{}) (string, bool) {
+	for pos, check := range k.predicate {
+		v := row[pos]
+		if v == nil || !check.Allows(valueString(v)) {
+			return "", false
+		}
+	}
+	parts := make([]string, len(k.positions))
+	for i, pos := range k.positions {
+		if row[pos] == nil {
+			// NULLs are distinct from each other
+			return "", false
+		}
+		parts[i] = valueString(row[pos])
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// valueString converts generated or scanned value to its text form
+func valueString(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+// add remembers values of the row if they are unique for every constraint.
+// Otherwise nothing is remembered and the violated constraint is returned.
+func (t *uniqueTracker) add(row []interface{}) (UniqueConstraint, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys := make([]string, len(t.keys))
+	covered := make([]bool, len(t.keys))
+	for i, k := range t.keys {
+		key, ok := k.rowKey(row)
+		if !ok {
+			continue
+		}
+		if k.seen[key] {
+			return k.constraint, false
+		}
+		keys[i] = key
+		covered[i] = true
+	}
+	for i, k := range t.keys {
+		if covered[i] {
+			k.seen[keys[i]] = true
+		}
+	}
+	return UniqueConstraint{}, true
+}
@@ -0,0 +1,46 @@
+package dbutils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUniqueTracker(t *testing.T) {
+	table := Table{
+		Schema: "public",
+		Name:   "users",
+		Uniques: []UniqueConstraint{
+			{Name: "users_pkey", Columns: []string{"user_id"}},
+			{Name: "users_email_key", Columns: []string{"email"}},
+			{Name: "users_tenant_login_key", Columns: []string{"tenant_id", "login"}},
+			{Name: "users_active_nick_idx", Columns: []string{"nick"}, Predicate: "(status = 'active'::text)"},
+		},
+	}
+	columns := []Column{{Name: "email"}, {Name: "tenant_id"}, {Name: "login"}, {Name: "nick"}, {Name: "status"}}
+	tracker, err := newUniqueTracker(nil, nil, table, columns)
+	assert.NoError(t, err)
+	// users_pkey is filled by the database
+	assert.Len(t, tracker.keys, 3)
+
+	_, ok := tracker.add([]interface{}{"a@x.io", int64(1), "a", "n", "active"})
+	assert.True(t, ok)
+
+	constraint, ok := tracker.add([]interface{}{"a@x.io", int64(2), "b", "m", "active"})
+	assert.False(t, ok)
+	assert.Equal(t, "users_email_key", constraint.Name)
+
+	_, ok = tracker.add([]interface{}{"b@x.io", int64(2), "a", "m", "active"})
+	assert.True(t, ok)
+
+	constraint, ok = tracker.add([]interface{}{"c@x.io", []byte("2"), "a", "k", "active"})
+	assert.False(t, ok)
+	assert.Equal(t, "users_tenant_login_key", constraint.Name)
+
+	// partial index covers only active users, NULLs never collide
+	_, ok = tracker.add([]interface{}{"d@x.io", int64(3), "a", "n", "blocked"})
+	assert.True(t, ok)
+	_, ok = tracker.add([]interface{}{nil, int64(3), nil, "z", "active"})
+	assert.True(t, ok)
+	_, ok = tracker.add([]interface{}{nil, int64(3), nil, "y", "active"})
+	assert.True(t, ok)
+}
@@ -4,48 +4,62 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/urfave/cli/v3"
 	"github.com/victornguen/db-faker/datagen"
 	"github.com/victornguen/db-faker/dbutils"
 	"log"
 	"os"
 	_ "sort"
+	"strings"
+	"time"
 )
 
 func main() {
 	app := &cli.Command{
 		Name:  "db_faker",
-		Usage: "Generate and insert fake data into a PostgreSQL database",
+		Usage: "Generate and insert fake data into a PostgreSQL, MySQL or SQLite database",
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "driver",
+				Usage:    "Database driver: postgres, mysql or sqlite",
+				Value:    "postgres",
+				Required: false,
+			},
 			&cli.StringFlag{
 				Name:     "host",
-				Usage:    "PostgreSQL host",
+				Usage:    "Database host",
 				Value:    "localhost",
 				Required: false,
 			},
 			&cli.IntFlag{
 				Name:     "port",
 				Aliases:  []string{"p"},
-				Usage:    "PostgreSQL port",
-				Value:    5432,
+				Usage:    "Database port, 5432 for postgres and 3306 for mysql by default",
 				Required: false,
 			},
 			&cli.StringFlag{
 				Name:     "user",
-				Usage:    "PostgreSQL user",
-				Required: true,
+				Usage:    "Database user",
+				Required: false,
 			},
 			&cli.StringFlag{
 				Name:     "password",
-				Usage:    "PostgreSQL password",
-				Required: true,
+				Usage:    "Database password",
+				Required: false,
 			},
 			&cli.StringFlag{
 				Name:     "dbname",
 				Aliases:  []string{"db"},
-				Usage:    "PostgreSQL database name",
-				Required: true,
+				Usage:    "Database name",
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "file",
+				Usage:    "Database file of sqlite",
+				Required: false,
 			},
 			&cli.StringFlag{
 				Name:     "rules",
@@ -54,13 +68,107 @@ func main() {
 				Value:    "./gen_settings.yaml",
 				Required: false,
 			},
+			&cli.StringSliceFlag{
+				Name:     "schema",
+				Usage:    "Schema to generate data for (can be repeated), all non-system schemas by default",
+				Required: false,
+			},
+			&cli.StringSliceFlag{
+				Name:     "exclude-schema",
+				Usage:    "Schema to skip (can be repeated)",
+				Required: false,
+			},
+			&cli.StringSliceFlag{
+				Name:     "tables",
+				Usage:    "Table name or glob (audit_*, billing.*) to generate data for (can be repeated), all tables by default",
+				Required: false,
+			},
+			&cli.StringSliceFlag{
+				Name:     "exclude-tables",
+				Usage:    "Table name or glob to skip (can be repeated)",
+				Required: false,
+			},
 		},
 		Commands: []*cli.Command{
 			{
-				Name:   "generate",
-				Usage:  "Generate and insert fake data",
+				Name:  "generate",
+				Usage: "Generate and insert fake data",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "schema-file",
+						Usage:    "Read tables from a file written by schema dump instead of introspecting the database",
+						Required: false,
+					},
+					&cli.StringFlag{
+						Name:     "ddl",
+						Usage:    "Read tables from a .sql file or a directory of migrations instead of introspecting the database",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "dry-run",
+						Usage:    "Generate rows of tables from --schema-file or --ddl without connecting to the database, nothing is written",
+						Required: false,
+					},
+					&cli.StringFlag{
+						Name:     "load-method",
+						Usage:    "How rows are sent: insert (row by row), batch (multi-row INSERT) or copy (PostgreSQL COPY)",
+						Value:    "insert",
+						Required: false,
+					},
+					&cli.IntFlag{
+						Name:     "batch-size",
+						Usage:    "Rows of one multi-row INSERT of --load-method batch",
+						Value:    1000,
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "atomic",
+						Usage:    "Run the whole generation in one transaction, any failed row rolls everything back",
+						Required: false,
+					},
+					&cli.IntFlag{
+						Name:     "commit-every",
+						Usage:    "Commit every N rows instead of every statement (0 commits every statement)",
+						Required: false,
+					},
+					&cli.IntFlag{
+						Name:     "workers",
+						Usage:    "Number of tables or parts of a large table loaded at the same time",
+						Value:    1,
+						Required: false,
+					},
+					&cli.IntFlag{
+						Name:     "max-connections",
+						Usage:    "Maximum number of open database connections (0 means one per worker)",
+						Required: false,
+					},
+				},
 				Action: generateData,
 			},
+			{
+				Name:  "schema",
+				Usage: "Work with the database schema",
+				Commands: []*cli.Command{
+					{
+						Name:  "dump",
+						Usage: "Save introspected tables to a file",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "output",
+								Aliases:  []string{"o"},
+								Usage:    "Path to the schema file",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "format",
+								Usage:    "Format of the schema file: json or yaml, taken from the file extension by default",
+								Required: false,
+							},
+						},
+						Action: dumpSchema,
+					},
+				},
+			},
 		},
 	}
 
@@ -70,45 +178,323 @@ func main() {
 
 }
 
-func generateData(c context.Context, command *cli.Command) error {
-	host := command.String("host")
-	port := command.Int("port")
-	user := command.String("user")
-	password := command.String("password")
-	dbname := command.String("dbname")
+func openDB(command *cli.Command) (*sql.DB, dbutils.Dialect, error) {
+	dialect, err := dbutils.DialectByName(command.String("driver"))
+	if err != nil {
+		return nil, nil, err
+	}
 
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
+	port := int(command.Int("port"))
+	if !command.IsSet("port") {
+		port = dialect.DefaultPort()
+	}
+	conn := dbutils.ConnConfig{
+		Host:     command.String("host"),
+		Port:     port,
+		User:     command.String("user"),
+		Password: command.String("password"),
+		DBName:   command.String("dbname"),
+		File:     command.String("file"),
+	}
 
-	db, err := sql.Open("postgres", psqlInfo)
+	dsn, err := dialect.DataSourceName(conn)
 	if err != nil {
+		return nil, nil, err
+	}
+	db, err := sql.Open(dialect.DriverName(), dsn)
+	return db, dialect, err
+}
+
+func schemaFilter(command *cli.Command) dbutils.SchemaFilter {
+	return dbutils.SchemaFilter{
+		Include: command.StringSlice("schema"),
+		Exclude: command.StringSlice("exclude-schema"),
+	}
+}
+
+func tableFilter(command *cli.Command, exclude []string) dbutils.TableFilter {
+	return dbutils.TableFilter{
+		Include: command.StringSlice("tables"),
+		Exclude: append(command.StringSlice("exclude-tables"), exclude...),
+	}
+}
+
+func dumpSchema(c context.Context, command *cli.Command) error {
+	output := command.String("output")
+	// check the format before introspection
+	if _, err := dbutils.SchemaFileFormat(output, command.String("format")); err != nil {
 		return err
 	}
-	defer db.Close()
 
-	rules, err := datagen.LoadRulesFromYAMLFile("./gen_settings.yaml")
+	db, dialect, err := openDB(command)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	tables, err := dbutils.GetTablesWithDependencies(db)
+	tables, err := dialect.Introspect(db, schemaFilter(command))
 	if err != nil {
 		return err
 	}
+	tables, err = dbutils.FilterTables(tables, tableFilter(command, nil))
+	if err != nil {
+		return err
+	}
+	if err := dbutils.WriteSchemaFile(output, command.String("format"), tables); err != nil {
+		return err
+	}
+	fmt.Printf("Saved %d tables to %s\n", len(tables), output)
+	return nil
+}
+
+// loadTables reads tables from the schema file or DDL given by flags, or introspects the database
+func loadTables(db *sql.DB, dialect dbutils.Dialect, command *cli.Command) ([]dbutils.Table, error) {
+	schemaFile, ddl := command.String("schema-file"), command.String("ddl")
+	switch {
+	case schemaFile != "" && ddl != "":
+		return nil, fmt.Errorf("--schema-file and --ddl can't be used together")
+	case schemaFile != "":
+		return dbutils.LoadSchemaFile(schemaFile, schemaFilter(command))
+	case ddl != "":
+		tables, unparsed, err := dbutils.ParseDDL(ddl, schemaFilter(command))
+		for _, stmt := range unparsed {
+			log.Printf("Warning: statement skipped: %s", stmt)
+		}
+		return tables, err
+	default:
+		return dialect.Introspect(db, schemaFilter(command))
+	}
+}
+
+// generationTargets filters and sorts tables, applies rules and returns the tables to generate rows for
+func generationTargets(tables []dbutils.Table, rules datagen.TablesRules, command *cli.Command) ([]dbutils.Table, error) {
+	tables, err := dbutils.FilterTables(tables, tableFilter(command, rules.Exclude))
+	if err != nil {
+		return nil, err
+	}
 
 	sortedTables := dbutils.TopologicalSort(tables)
 
 	err = dbutils.ApplyRulesToTables(&sortedTables, rules)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	for _, cycle := range dbutils.FindCycles(sortedTables) {
+		log.Printf("Warning: %s", cycle)
 	}
 
+	targets := make([]dbutils.Table, 0, len(sortedTables))
 	for _, table := range sortedTables {
-		err := dbutils.GenerateAndInsertData(db, table)
+		if !table.Insertable() {
+			// partitions are filled through their parent
+			if table.Kind != dbutils.KindPartition {
+				log.Printf("Skipping %s %s", table.Kind, table.QualifiedName())
+			}
+			continue
+		}
+		if table.RowNum == 0 {
+			// no rule with num, the table is only referenced
+			continue
+		}
+		targets = append(targets, table)
+	}
+	// warnings matter only for tables rows are generated for
+	for _, table := range targets {
+		for _, warning := range table.Warnings {
+			log.Printf("Warning: %s", warning)
+		}
+	}
+
+	return targets, nil
+}
+
+func generateData(c context.Context, command *cli.Command) error {
+	if command.Bool("dry-run") {
+		return dryRun(command)
+	}
+
+	db, dialect, err := openDB(command)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	method, err := dbutils.ParseLoadMethod(command.String("load-method"), dialect)
+	if err != nil {
+		return err
+	}
+
+	rules, err := datagen.LoadRulesFromYAMLFile(command.String("rules"))
+	if err != nil {
+		return err
+	}
+
+	tables, err := loadTables(db, dialect, command)
+	if err != nil {
+		return err
+	}
+	targets, err := generationTargets(tables, rules, command)
+	if err != nil {
+		return err
+	}
+
+	// tables which are not generated must already have rows for the generated ones to reference
+	missing, err := dbutils.MissingParents(db, dialect, targets)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		for _, msg := range missing {
+			log.Printf("Missing parent: %s", msg)
+		}
+		return fmt.Errorf("%d foreign keys reference tables without rows, nothing was inserted", len(missing))
+	}
+
+	sessions, err := openSessions(db, command)
+	if err != nil {
+		return err
+	}
+
+	opts := dbutils.LoadOptions{Dialect: dialect, Method: method, BatchSize: int(command.Int("batch-size"))}
+	start := time.Now()
+	total := 0
+	failed := false
+	for _, result := range dbutils.LoadGroups(sessions, opts, dbutils.InsertGroups(targets)) {
+		total += result.Inserted
+		if result.Err != nil {
+			names := make([]string, 0, len(result.Group))
+			for _, table := range result.Group {
+				names = append(names, table.QualifiedName())
+			}
+			log.Printf("Error inserting data into %s: %v", strings.Join(names, ", "), result.Err)
+			failed = failed || sessions[0].Atomic()
+		}
+	}
+
+	var finishErr error
+	for _, session := range sessions {
+		if failed {
+			err = session.Rollback()
+		} else {
+			err = session.Commit()
+		}
 		if err != nil {
-			log.Printf("Error inserting data into %s: %v", table.Name, err)
+			log.Printf("Error finishing the run: %v", err)
+			if finishErr == nil {
+				finishErr = err
+			}
 		}
 	}
 
+	elapsed := time.Since(start)
+	fmt.Printf("Inserted %d rows in %s (%.0f rows/s)\n",
+		total, elapsed.Round(time.Millisecond), dbutils.RowsPerSecond(total, elapsed))
+	printCommitted(sessions, targets)
+	if failed {
+		return fmt.Errorf("atomic run failed, all inserted rows were rolled back")
+	}
+	if finishErr != nil {
+		return fmt.Errorf("can't commit inserted rows: %v", finishErr)
+	}
 	return nil
 }
+
+// dryRun generates rows of tables read from --schema-file or --ddl without connecting to the database
+func dryRun(command *cli.Command) error {
+	if command.String("schema-file") == "" && command.String("ddl") == "" {
+		return fmt.Errorf("--dry-run reads tables from --schema-file or --ddl")
+	}
+
+	rules, err := datagen.LoadRulesFromYAMLFile(command.String("rules"))
+	if err != nil {
+		return err
+	}
+	tables, err := loadTables(nil, nil, command)
+	if err != nil {
+		return err
+	}
+	targets, err := generationTargets(tables, rules, command)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	failed := 0
+	for _, result := range dbutils.DryRun(targets) {
+		total += result.Rows
+		if result.Err != nil {
+			log.Printf("Error generating rows for %s: %v", result.Table.QualifiedName(), result.Err)
+			failed++
+			continue
+		}
+		fmt.Printf("Generated %d rows for %s\n", result.Rows, result.Table.QualifiedName())
+		if result.Sample != "" {
+			fmt.Printf("  first row: %s\n", result.Sample)
+		}
+	}
+	fmt.Printf("Dry run generated %d rows of %d tables, nothing was written\n", total, len(targets))
+	if failed > 0 {
+		return fmt.Errorf("rows of %d tables can't be generated", failed)
+	}
+	return nil
+}
+
+// openSessions returns a session for every worker and limits connections of db
+func openSessions(db *sql.DB, command *cli.Command) ([]*dbutils.Session, error) {
+	workers := int(command.Int("workers"))
+	maxConnections := int(command.Int("max-connections"))
+	atomic := command.Bool("atomic")
+	commitEvery := int(command.Int("commit-every"))
+	if workers < 1 {
+		return nil, fmt.Errorf("at least one worker is needed, got %d", workers)
+	}
+	if maxConnections == 0 {
+		maxConnections = workers
+	}
+	if atomic && workers > 1 {
+		return nil, fmt.Errorf("atomic run is one transaction, it can't be loaded by %d workers", workers)
+	}
+	if commitEvery > 0 && maxConnections < workers {
+		return nil, fmt.Errorf("--commit-every keeps a transaction open per worker, "+
+			"%d workers need %d connections, --max-connections is %d", workers, workers, maxConnections)
+	}
+	db.SetMaxOpenConns(maxConnections)
+
+	sessions := make([]*dbutils.Session, 0, workers)
+	for i := 0; i < workers; i++ {
+		session, err := dbutils.NewSession(db, atomic, commitEvery)
+		if err != nil {
+			for _, opened := range sessions {
+				_ = opened.Rollback()
+			}
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// printCommitted states which tables got committed rows and which didn't
+func printCommitted(sessions []*dbutils.Session, tables []dbutils.Table) {
+	committed := make([]string, 0, len(tables))
+	notCommitted := make([]string, 0)
+	for _, table := range tables {
+		rows := 0
+		for _, session := range sessions {
+			rows += session.Committed(table)
+		}
+		if rows > 0 {
+			committed = append(committed, fmt.Sprintf("%s (%d rows)", table.QualifiedName(), rows))
+		} else {
+			notCommitted = append(notCommitted, table.QualifiedName())
+		}
+	}
+	if len(committed) == 0 {
+		committed = append(committed, "none")
+	}
+	fmt.Printf("Committed: %s\n", strings.Join(committed, ", "))
+	if len(notCommitted) > 0 {
+		fmt.Printf("Not committed: %s\n", strings.Join(notCommitted, ", "))
+	}
+}